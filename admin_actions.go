@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"net/http"
+)
+
+// requireAdmin checks the ApiKey header against ADMIN_KEY
+func (cfg *apiConfig) requireAdmin(r *http.Request) error {
+	if cfg.adminKey == "" {
+		return errors.New("admin api is disabled")
+	}
+
+	key, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return err
+	}
+
+	if !auth.CompareTokens(key, cfg.adminKey) {
+		return errors.New("invalid admin key")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// viewerFromRequest returns the authenticated user when the request carries
+// a token; anonymous requests get a null id, a bad token is still an error
+func (cfg *apiConfig) viewerFromRequest(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+// isBlocked reports whether either user blocked the other
+func (cfg *apiConfig) isBlocked(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	return cfg.dbQueries.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+		BlockerID: a,
+		BlockedID: b,
+	})
+}
+
+// targetUser authenticates the caller and parses the user from the path
+func (cfg *apiConfig) targetUser(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err = uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user id", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't do that to yourself", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+func (cfg *apiConfig) handlerBlock(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.dbQueries.GetUser(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	_, err = q.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+
+	// a block ends the relationship in both directions
+	err = q.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblock(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.dbQueries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unblock user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.dbQueries.GetUser(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.dbQueries.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unmute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
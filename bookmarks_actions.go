@@ -0,0 +1,396 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/pagination"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxBookmarkFolderNameLength = 50
+
+type Bookmark struct {
+	ChirpID   uuid.UUID  `json:"chirp_id"`
+	FolderID  *uuid.UUID `json:"folder_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Available is false once the chirp is no longer visible to the owner
+	// of the bookmark, Chirp is left out then
+	Available bool   `json:"available"`
+	Chirp     *Chirp `json:"chirp,omitempty"`
+}
+
+type BookmarkFolder struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+func bookmarkFolderFromDB(folder database.BookmarkFolder) BookmarkFolder {
+	return BookmarkFolder{
+		ID:        folder.ID,
+		CreatedAt: folder.CreatedAt.Time,
+		UpdatedAt: folder.UpdatedAt.Time,
+		Name:      folder.Name,
+	}
+}
+
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		FolderID *uuid.UUID `json:"folder_id"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	// the body is optional, without one the bookmark isn't in a folder
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetChirpForViewer(r.Context(), database.GetChirpForViewerParams{
+		ID:       chirpUUID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	folderID := uuid.NullUUID{}
+	if params.FolderID != nil {
+		_, err = cfg.dbQueries.GetBookmarkFolder(r.Context(), database.GetBookmarkFolderParams{
+			ID:     *params.FolderID,
+			UserID: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't find folder", err)
+			return
+		}
+		folderID = uuid.NullUUID{UUID: *params.FolderID, Valid: true}
+	}
+
+	// bookmarking again moves the bookmark but keeps its place in the list
+	bookmark, err := cfg.dbQueries.SaveBookmark(r.Context(), database.SaveBookmarkParams{
+		UserID:   userID,
+		ChirpID:  chirpUUID,
+		FolderID: folderID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save bookmark", err)
+		return
+	}
+
+	resp := Bookmark{
+		ChirpID:   bookmark.ChirpID,
+		CreatedAt: bookmark.CreatedAt,
+		Available: true,
+	}
+	if bookmark.FolderID.Valid {
+		resp.FolderID = &bookmark.FolderID.UUID
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerUnbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	// unavailable chirps can still be removed
+	_, err = cfg.dbQueries.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpUUID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete bookmark", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerBookmarks(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Bookmarks  []Bookmark `json:"bookmarks"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	params := database.ListBookmarksParams{
+		UserID:       userID,
+		MaxBookmarks: int32(limit),
+	}
+	if folderID := r.URL.Query().Get("folder_id"); folderID != "" {
+		folderUUID, err := uuid.Parse(folderID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid folder id", err)
+			return
+		}
+		params.FolderID = uuid.NullUUID{UUID: folderUUID, Valid: true}
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		beforeAt, beforeID, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		params.BeforeAt = sql.NullTime{Time: beforeAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: beforeID, Valid: true}
+	}
+
+	list, err := cfg.dbQueries.ListBookmarks(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list bookmarks", err)
+		return
+	}
+
+	var chirps []Chirp
+	for _, b := range list {
+		if b.Available {
+			chirps = append(chirps, chirpFromDB(b.Chirp))
+		}
+	}
+	err = cfg.loadChirpDetails(r.Context(), cfg.dbQueries, uuid.NullUUID{UUID: userID, Valid: true}, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list bookmarks", err)
+		return
+	}
+
+	resp := response{
+		Bookmarks: []Bookmark{},
+	}
+	for _, b := range list {
+		bookmark := Bookmark{
+			ChirpID:   b.ChirpID,
+			CreatedAt: b.CreatedAt,
+			Available: b.Available,
+		}
+		if b.FolderID.Valid {
+			bookmark.FolderID = &b.FolderID.UUID
+		}
+		if b.Available {
+			bookmark.Chirp = &chirps[0]
+			chirps = chirps[1:]
+		}
+		resp.Bookmarks = append(resp.Bookmarks, bookmark)
+	}
+	if len(list) == limit {
+		last := list[len(list)-1]
+		resp.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ChirpID)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerBookmarkFolders(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	folders, err := cfg.dbQueries.ListBookmarkFolders(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list folders", err)
+		return
+	}
+
+	resp := []BookmarkFolder{}
+	for _, folder := range folders {
+		resp = append(resp, bookmarkFolderFromDB(folder))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// bookmarkFolderName reads and checks the name of a folder being created or
+// renamed
+func bookmarkFolderName(r *http.Request) (string, error) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > maxBookmarkFolderNameLength {
+		return "", errors.New("folder name must be 1 to 50 characters")
+	}
+	return name, nil
+}
+
+func (cfg *apiConfig) handlerBookmarkFoldersCreate(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	name, err := bookmarkFolderName(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Folder name must be 1 to 50 characters", err)
+		return
+	}
+
+	folder, err := cfg.dbQueries.CreateBookmarkFolder(r.Context(), database.CreateBookmarkFolderParams{
+		UserID: userID,
+		Name:   name,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "You already have a folder with that name", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create folder", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, bookmarkFolderFromDB(folder))
+}
+
+func (cfg *apiConfig) handlerBookmarkFolderUpdate(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	folderUUID, err := uuid.Parse(r.PathValue("folderID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid folder id", err)
+		return
+	}
+
+	name, err := bookmarkFolderName(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Folder name must be 1 to 50 characters", err)
+		return
+	}
+
+	folder, err := cfg.dbQueries.RenameBookmarkFolder(r.Context(), database.RenameBookmarkFolderParams{
+		ID:     folderUUID,
+		UserID: userID,
+		Name:   name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find folder", err)
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "You already have a folder with that name", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rename folder", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, bookmarkFolderFromDB(folder))
+}
+
+func (cfg *apiConfig) handlerBookmarkFolderDelete(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	folderUUID, err := uuid.Parse(r.PathValue("folderID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid folder id", err)
+		return
+	}
+
+	// the bookmarks in it are kept, outside any folder
+	deleted, err := cfg.dbQueries.DeleteBookmarkFolder(r.Context(), database.DeleteBookmarkFolderParams{
+		ID:     folderUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete folder", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find folder", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,22 +1,85 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Weso1ek/chirpy/internal/auth"
 	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/entitlements"
+	"github.com/Weso1ek/chirpy/internal/filters"
+	"github.com/Weso1ek/chirpy/internal/handles"
+	"github.com/Weso1ek/chirpy/internal/notifications"
+	"github.com/Weso1ek/chirpy/internal/pagination"
+	"github.com/Weso1ek/chirpy/internal/stream"
+	"github.com/Weso1ek/chirpy/internal/visibility"
 	"net/http"
-	"sort"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const maxChirpMentions = 20
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserId    uuid.UUID `json:"user_id"`
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Body       string       `json:"body"`
+	UserId     uuid.UUID    `json:"user_id"`
+	ReplyToID  *uuid.UUID   `json:"reply_to_id,omitempty"`
+	Visibility string       `json:"visibility"`
+	Media      []Media      `json:"media,omitempty"`
+	Filtered   *ChirpFilter `json:"filtered,omitempty"`
+	// Pinned is only set when listing a single author's chirps
+	Pinned bool  `json:"pinned,omitempty"`
+	Poll   *Poll `json:"poll,omitempty"`
+	// DeletedAt is only set when listing the viewer's deleted chirps
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	resp := Chirp{
+		ID:         chirp.ID,
+		CreatedAt:  chirp.CreatedAt.Time,
+		UpdatedAt:  chirp.UpdatedAt.Time,
+		Body:       chirp.Body,
+		UserId:     chirp.UserID,
+		Visibility: chirp.Visibility,
+	}
+	if chirp.ReplyToID.Valid {
+		resp.ReplyToID = &chirp.ReplyToID.UUID
+	}
+	if chirp.DeletedAt.Valid {
+		resp.DeletedAt = &chirp.DeletedAt.Time
+	}
+	return resp
+}
+
+// loadChirpDetails fills in the media and polls of chirps as viewer sees
+// them
+func (cfg *apiConfig) loadChirpDetails(ctx context.Context, q *database.Queries, viewer uuid.NullUUID, chirps []Chirp) error {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	attachments, err := cfg.chirpMedia(ctx, q, chirpIDs...)
+	if err != nil {
+		return err
+	}
+	chirpPollsByID, err := chirpPolls(ctx, q, viewer, chirpIDs...)
+	if err != nil {
+		return err
+	}
+	for i := range chirps {
+		chirps[i].Media = attachments[chirps[i].ID]
+		chirps[i].Poll = chirpPollsByID[chirps[i].ID]
+	}
+	return nil
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -48,11 +111,43 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 
 	if chirp.UserID != userID {
 		respondWithError(w, http.StatusForbidden, "Forbidden", err)
+		return
 	}
 
-	errDb := cfg.dbQueries.DeleteChirp(r.Context(), chirpUUID)
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	errDb := q.DeleteChirp(r.Context(), chirpUUID)
 	if errDb != nil {
 		respondWithError(w, http.StatusNotFound, "Couldn't delete chirp", errDb)
+		return
+	}
+
+	// a restored chirp comes back unpinned, the pin limit may be used up by then
+	_, err = q.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpUUID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+
+	err = publishChirpEvent(r.Context(), q, stream.EventChirpDeleted, chirpFromDB(chirp))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusNoContent, nil)
@@ -67,7 +162,17 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, errDb := cfg.dbQueries.GetChirp(r.Context(), chirpUUID)
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// chirps across a block are reported missing rather than forbidden
+	chirp, errDb := cfg.dbQueries.GetChirpForViewer(r.Context(), database.GetChirpForViewerParams{
+		ID:       chirpUUID,
+		ViewerID: viewer,
+	})
 
 	type response struct {
 		Chirp
@@ -75,57 +180,423 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 
 	if errDb != nil {
 		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", errDb)
+		return
+	}
+
+	resp := []Chirp{chirpFromDB(chirp)}
+	err = cfg.loadChirpDetails(r.Context(), cfg.dbQueries, viewer, resp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load chirp", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		Chirp: Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt.Time,
-			UpdatedAt: chirp.UpdatedAt.Time,
-			Body:      chirp.Body,
-			UserId:    chirp.UserID,
-		},
+		Chirp: resp[0],
 	})
 }
 
+// maxPageScans bounds how many batches a listing reads to fill a page when
+// muted words hide most of what it finds, the cursor picks up from there
+const maxPageScans = 5
+
+// handlerChirps lists every visible chirp unless limit or cursor is given,
+// then it returns one page and the cursor of the next in X-Next-Cursor
 func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request) {
-	sortParam := r.URL.Query().Get("sort")
-	authorId := r.URL.Query().Get("author_id")
-	authorUUID, _ := uuid.Parse(authorId)
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	cursor := r.URL.Query().Get("cursor")
+	paginate := cursor != "" || r.URL.Query().Has("limit")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	params := database.ListChirpsForViewerParams{
+		ViewerID:    viewer,
+		NewestFirst: r.URL.Query().Get("sort") == "desc",
+		MaxChirps:   sql.NullInt32{Int32: int32(limit), Valid: paginate},
+	}
+	if authorId := r.URL.Query().Get("author_id"); authorId != "" {
+		authorUUID, err := uuid.Parse(authorId)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author id", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: authorUUID, Valid: true}
+	}
+	if cursor != "" {
+		afterAt, afterID, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		params.AfterAt = sql.NullTime{Time: afterAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: afterID, Valid: true}
+	}
+
+	var mutedWords []filters.Filter
+	if viewer.Valid {
+		mutedWords, err = mutedWordFilters(r.Context(), cfg.dbQueries, viewer.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load muted words", err)
+			return
+		}
+	}
 
-	chirps, err := cfg.dbQueries.ListChirps(r.Context())
+	var pinned []Chirp
+	if params.AuthorID.Valid && cursor == "" {
+		pinned, err = cfg.pinnedChirps(r.Context(), params.AuthorID.UUID, viewer)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	filter := func(chirp *Chirp) bool {
+		// muted words never apply to the viewer's own chirps
+		if chirp.UserId == viewer.UUID {
+			return true
+		}
+		match := filters.Match(mutedWords, chirp.Body, now)
+		if match.Hidden() {
+			return false
+		}
+		if match.Action != "" {
+			chirp.Filtered = &ChirpFilter{Action: match.Action, Matched: match.Matched}
+		}
+		return true
+	}
+
+	chirpsResp := []Chirp{}
+	nextCursor := ""
+	// muted chirps are dropped here rather than in the query, so a page
+	// keeps reading until it is full or the chirps run out
+	for scan := 0; scan < maxPageScans; scan++ {
+		chirps, err := cfg.dbQueries.ListChirpsForViewer(r.Context(), params)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
+			return
+		}
+
+		for _, chirp := range chirps {
+			nextCursor = pagination.EncodeCursor(chirp.CreatedAt.Time, chirp.ID)
+			params.AfterAt = chirp.CreatedAt
+			params.AfterID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+
+			c := chirpFromDB(chirp)
+			if !filter(&c) {
+				continue
+			}
+			chirpsResp = append(chirpsResp, c)
+			if paginate && len(chirpsResp) == limit {
+				break
+			}
+		}
+		if !paginate || len(chirpsResp) == limit {
+			break
+		}
+		if len(chirps) < limit {
+			nextCursor = ""
+			break
+		}
+	}
 
+	err = cfg.loadChirpDetails(r.Context(), cfg.dbQueries, viewer, chirpsResp)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
 		return
 	}
 
-	var chirpsResp []Chirp
+	if len(pinned) > 0 {
+		shown := []Chirp{}
+		for _, chirp := range pinned {
+			if filter(&chirp) {
+				shown = append(shown, chirp)
+			}
+		}
+		chirpsResp = pinFirst(chirpsResp, shown)
+	}
+
+	if paginate && nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+	respondWithJSON(w, http.StatusOK, chirpsResp)
+}
+
+// notifyMentions tells each mentioned user about chirp, leaving out the
+// author and anyone who can't see it, blocked users included
+func notifyMentions(ctx context.Context, q *database.Queries, chirp database.Chirp, mentions []uuid.UUID) error {
+	seen := map[uuid.UUID]bool{chirp.UserID: true}
+	for _, id := range mentions {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		_, err := q.GetChirpForViewer(ctx, database.GetChirpForViewerParams{
+			ID:       chirp.ID,
+			ViewerID: uuid.NullUUID{UUID: id, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = notify(ctx, q, newNotification{
+			UserID:  id,
+			Type:    notifications.TypeMention,
+			ActorID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Subject: chirp.ID,
+			Text:    chirp.Body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chirpInput is what a chirp is made from, whether it's posted right away
+// or later from a scheduled chirp
+type chirpInput struct {
+	Body       string      `json:"body"`
+	ReplyToID  *uuid.UUID  `json:"reply_to_id"`
+	Visibility string      `json:"visibility"`
+	Mentions   []uuid.UUID `json:"mentions"`
+	MediaIDs   []uuid.UUID `json:"media_ids"`
+	Poll       *pollInput  `json:"poll"`
+}
+
+// chirpInputError is a chirp that can't be posted as requested, Status is
+// what the API answers with
+type chirpInputError struct {
+	Status  int
+	Message string
+}
+
+func (e *chirpInputError) Error() string {
+	return e.Message
+}
+
+func invalidChirp(message string) error {
+	return &chirpInputError{Status: http.StatusBadRequest, Message: message}
+}
+
+// respondWithChirpError answers with the status of a chirpInputError, or
+// a 500 with msg for anything else
+func respondWithChirpError(w http.ResponseWriter, err error, msg string) {
+	var inputErr *chirpInputError
+	if errors.As(err, &inputErr) {
+		respondWithError(w, inputErr.Status, inputErr.Message, nil)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, msg, err)
+}
+
+// checkChirpInput runs the checks that don't need the database for a
+// chirp posted at postedAt, defaulting the visibility to public
+func checkChirpInput(limits entitlements.Limits, in *chirpInput, postedAt time.Time) error {
+	if len(in.Body) > limits.MaxChirpLength {
+		return invalidChirp("Chirp is too long")
+	}
+
+	if in.Visibility == "" {
+		in.Visibility = visibility.Public
+	}
+	if !visibility.Valid(in.Visibility) {
+		return invalidChirp("Visibility must be public, unlisted, followers or mentioned")
+	}
+
+	if len(in.Mentions) > maxChirpMentions {
+		return invalidChirp("Too many mentions")
+	}
+
+	if len(in.MediaIDs) > limits.MediaPerChirp {
+		return invalidChirp(fmt.Sprintf("A chirp can have at most %d attachments", limits.MediaPerChirp))
+	}
+
+	if in.Poll != nil {
+		return checkPollInput(in.Poll, postedAt)
+	}
+	return nil
+}
+
+// createChirp posts in as userID within q's transaction. Chirps posted
+// right away and scheduled ones go through the same checks and side
+// effects here. It publishes the chirp event last, so callers commit right
+// after it.
+func (cfg *apiConfig) createChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, in chirpInput) (Chirp, error) {
+	// access tokens outlive a deleted account and scheduled chirps are
+	// published without one
+	_, err := q.GetUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, &chirpInputError{Status: http.StatusForbidden, Message: "Account is deleted"}
+	}
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	limits, err := cfg.limitsFor(ctx, userID)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	err = checkChirpInput(limits, &in, time.Now().UTC())
+	if err != nil {
+		return Chirp{}, err
+	}
 
-	for _, j := range chirps {
-		if authorId == "" || (authorId != "" && j.UserID == authorUUID) {
-			chirpsResp = append(chirpsResp, Chirp{
-				ID:        j.ID,
-				CreatedAt: j.CreatedAt.Time,
-				UpdatedAt: j.UpdatedAt.Time,
-				Body:      j.Body,
-				UserId:    j.UserID,
-			})
+	if limits.ChirpsPerHour > 0 {
+		// concurrent posts would each count the same chirps and all get
+		// in, so they take turns on the user row
+		err = q.LockUser(ctx, userID)
+		if err != nil {
+			return Chirp{}, err
+		}
+		count, err := q.CountChirpsByUserSince(ctx, database.CountChirpsByUserSinceParams{
+			UserID:    userID,
+			CreatedAt: sql.NullTime{Time: time.Now().UTC().Add(-time.Hour), Valid: true},
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+		if count >= int64(limits.ChirpsPerHour) {
+			return Chirp{}, &chirpInputError{Status: http.StatusTooManyRequests, Message: "Too many chirps, try again later"}
 		}
 	}
 
-	if sortParam == "desc" {
-		sort.Slice(chirpsResp, func(i, j int) bool {
-			return chirpsResp[j].CreatedAt.Before(chirpsResp[i].CreatedAt)
+	var parent database.Chirp
+	if in.ReplyToID != nil {
+		// a private parent the author can't see doesn't exist for them
+		parent, err = q.GetChirpForViewer(ctx, database.GetChirpForViewerParams{
+			ID:       *in.ReplyToID,
+			ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return Chirp{}, invalidChirp("Couldn't find chirp to reply to")
+		}
+		if err != nil {
+			return Chirp{}, err
+		}
+
+		blocked, err := q.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+			BlockerID: userID,
+			BlockedID: parent.UserID,
 		})
+		if err != nil {
+			return Chirp{}, err
+		}
+		if blocked {
+			return Chirp{}, &chirpInputError{Status: http.StatusForbidden, Message: "You can't reply to this chirp"}
+		}
 	}
 
-	respondWithJSON(w, http.StatusOK, chirpsResp)
+	chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:       in.Body,
+		UserID:     userID,
+		ReplyToID:  uuid.NullUUID{UUID: parent.ID, Valid: in.ReplyToID != nil},
+		Visibility: in.Visibility,
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	// @handles in the body count as mentions too, unknown users are
+	// skipped rather than failing the chirp
+	mentions := in.Mentions
+	if mentioned := handles.Mentions(chirp.Body); len(mentioned) > 0 {
+		ids, err := q.ListUserIDsByHandles(ctx, mentioned)
+		if err != nil {
+			return Chirp{}, err
+		}
+		mentions = append(mentions, ids...)
+	}
+	if len(mentions) > 0 {
+		err = q.CreateChirpMentions(ctx, database.CreateChirpMentionsParams{
+			ChirpID: chirp.ID,
+			UserIds: mentions,
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	// only the author's own uploads that aren't on another chirp yet
+	if len(in.MediaIDs) > 0 {
+		attached, err := q.AttachChirpMedia(ctx, database.AttachChirpMediaParams{
+			ChirpID:  chirp.ID,
+			MediaIds: in.MediaIDs,
+			UserID:   userID,
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+		if attached != int64(len(in.MediaIDs)) {
+			return Chirp{}, invalidChirp("Media must be yours, processed and not attached elsewhere")
+		}
+	}
+
+	if in.Poll != nil {
+		err = createPoll(ctx, q, chirp.ID, *in.Poll)
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	// the same payload goes out to every webhook subscriber, so the poll is
+	// loaded as nobody in particular sees it
+	details := []Chirp{chirpFromDB(chirp)}
+	err = cfg.loadChirpDetails(ctx, q, uuid.NullUUID{}, details)
+	if err != nil {
+		return Chirp{}, err
+	}
+	resp := details[0]
+
+	if in.ReplyToID != nil {
+		err = notify(ctx, q, newNotification{
+			UserID:  parent.UserID,
+			Type:    notifications.TypeReply,
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Subject: chirp.ID,
+			Text:    chirp.Body,
+			Data: struct {
+				ReplyToID uuid.UUID `json:"reply_to_id"`
+			}{
+				ReplyToID: parent.ID,
+			},
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	err = notifyMentions(ctx, q, chirp, mentions)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	err = publishChirpEvent(ctx, q, stream.EventChirpCreated, resp)
+	if err != nil {
+		return Chirp{}, err
+	}
+	return resp, nil
 }
 
 func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		chirpInput
+		// a draft or a publish_at makes a scheduled chirp instead
+		Draft     bool       `json:"draft"`
+		PublishAt *time.Time `json:"publish_at"`
 	}
 	type response struct {
 		Chirp
@@ -150,39 +621,149 @@ func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	type returnValsError struct {
-		Error string `json:"error"`
+	if params.Draft || params.PublishAt != nil {
+		cfg.scheduleChirp(w, r, userID, params.chirpInput, params.Draft, params.PublishAt)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	chirp, err := cfg.createChirp(r.Context(), q, userID, params.chirpInput)
+	if err != nil {
+		respondWithChirpError(w, err, "Couldn't create chirp")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		Chirp: chirp,
+	})
+}
+
+func (cfg *apiConfig) handlerChirpsUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body *string `json:"body"`
+		// Visibility can be changed after the edit window closes
+		Visibility string `json:"visibility"`
+	}
+	type response struct {
+		Chirp
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
 	}
 
-	if len(params.Body) > 140 {
-		respBodyError := returnValsError{
-			Error: "Chirp is too long",
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+		return
+	}
+
+	body := chirp.Body
+	if params.Body != nil && *params.Body != chirp.Body {
+		limits, err := cfg.limitsFor(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load entitlements", err)
+			return
 		}
 
-		datErr, _ := json.Marshal(respBodyError)
+		if !limits.CanEdit(chirp.CreatedAt.Time, time.Now().UTC()) {
+			respondWithError(w, http.StatusForbidden, "Chirp can no longer be edited", nil)
+			return
+		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write(datErr)
+		if len(*params.Body) > limits.MaxChirpLength {
+			respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+			return
+		}
+		body = *params.Body
+	}
+
+	if params.Visibility == "" {
+		params.Visibility = chirp.Visibility
+	}
+	if !visibility.Valid(params.Visibility) {
+		respondWithError(w, http.StatusBadRequest, "Visibility must be public, unlisted, followers or mentioned", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
 	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
 
-	// CREATE CHIRP
-	chirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: userID,
+	chirp, err = q.UpdateChirp(r.Context(), database.UpdateChirpParams{
+		ID:         chirpUUID,
+		Body:       body,
+		Visibility: params.Visibility,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, response{
-		Chirp: Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt.Time,
-			UpdatedAt: chirp.UpdatedAt.Time,
-			Body:      chirp.Body,
-			UserId:    chirp.UserID,
-		},
+	details := []Chirp{chirpFromDB(chirp)}
+	err = cfg.loadChirpDetails(r.Context(), q, uuid.NullUUID{}, details)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+	resp := details[0]
+
+	err = publishChirpEvent(r.Context(), q, stream.EventChirpEdited, resp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Chirp: resp,
 	})
 }
@@ -0,0 +1,709 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/pagination"
+	"github.com/Weso1ek/chirpy/internal/realtime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationSize = 10
+	maxMessageLength    = 2000
+)
+
+var errCannotMessage = errors.New("user can't be messaged")
+
+type Participant struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Conversation struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	IsGroup      bool          `json:"is_group"`
+	Participants []Participant `json:"participants"`
+	UnreadCount  int64         `json:"unread_count"`
+}
+
+type Message struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	SenderID       uuid.UUID  `json:"sender_id"`
+	Body           string     `json:"body"`
+	EditedAt       *time.Time `json:"edited_at"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+}
+
+func participantFromDB(p database.ConversationParticipant) Participant {
+	participant := Participant{
+		UserID: p.UserID,
+	}
+	if p.LastReadAt.Valid {
+		participant.LastReadAt = &p.LastReadAt.Time
+	}
+	return participant
+}
+
+func messageFromDB(m database.Message) Message {
+	message := Message{
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Body:           m.Body,
+	}
+	if m.EditedAt.Valid {
+		message.EditedAt = &m.EditedAt.Time
+	}
+	if m.DeletedAt.Valid {
+		message.DeletedAt = &m.DeletedAt.Time
+	}
+	return message
+}
+
+// directKey identifies the 1:1 thread between a and b whoever started it
+func directKey(a, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+// canMessage reports whether senderID may start or continue a thread with
+// recipientID, private accounts only hear from their followers
+func (cfg *apiConfig) canMessage(ctx context.Context, senderID, recipientID uuid.UUID) error {
+	recipient, err := cfg.dbQueries.GetUser(ctx, recipientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errCannotMessage
+	}
+	if err != nil {
+		return err
+	}
+
+	if recipient.IsPrivate {
+		following, err := cfg.dbQueries.IsFollowing(ctx, database.IsFollowingParams{
+			FollowerID: senderID,
+			FolloweeID: recipientID,
+		})
+		if err != nil {
+			return err
+		}
+		if !following {
+			return errCannotMessage
+		}
+	}
+
+	return cfg.notBlocked(ctx, senderID, recipientID)
+}
+
+// notBlocked returns errCannotMessage when either user blocked the other
+func (cfg *apiConfig) notBlocked(ctx context.Context, senderID, recipientID uuid.UUID) error {
+	blocked, err := cfg.isBlocked(ctx, senderID, recipientID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errCannotMessage
+	}
+	return nil
+}
+
+// publishToParticipants pushes a realtime message to everyone in the
+// conversation except the user that caused it
+func publishToParticipants(ctx context.Context, q *database.Queries, conversationID, except uuid.UUID, msgType string, data any) error {
+	participants, err := q.ListConversationParticipants(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, p := range participants {
+		if p.UserID == except {
+			continue
+		}
+		err = publishRealtime(ctx, q, realtime.NotificationsTopic(p.UserID), msgType, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// participantConversation loads the conversation from the path, answering
+// 404 when the caller isn't part of it so its existence isn't revealed
+func (cfg *apiConfig) participantConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Conversation, bool) {
+	conversationUUID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation id", err)
+		return database.Conversation{}, false
+	}
+
+	conversation, err := cfg.dbQueries.GetConversationForParticipant(r.Context(), database.GetConversationForParticipantParams{
+		ID:     conversationUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation", err)
+		return database.Conversation{}, false
+	}
+	return conversation, true
+}
+
+func (cfg *apiConfig) handlerConversationsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	seen := map[uuid.UUID]bool{userID: true}
+	members := []uuid.UUID{userID}
+	for _, id := range params.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			members = append(members, id)
+		}
+	}
+	if len(members) < 2 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs someone else", nil)
+		return
+	}
+	if len(members) > maxConversationSize {
+		respondWithError(w, http.StatusBadRequest, "Too many participants", nil)
+		return
+	}
+
+	for _, id := range members[1:] {
+		err = cfg.canMessage(r.Context(), userID, id)
+		if errors.Is(err, errCannotMessage) {
+			respondWithError(w, http.StatusForbidden, "You can't message "+id.String(), err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+			return
+		}
+	}
+
+	isGroup := len(members) > 2
+	key := sql.NullString{}
+	if !isGroup {
+		key = sql.NullString{String: directKey(members[0], members[1]), Valid: true}
+		existing, err := cfg.dbQueries.GetConversationByDirectKey(r.Context(), key)
+		if err == nil {
+			conversation, err := cfg.conversationResponse(r.Context(), existing, 0)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't load conversation", err)
+				return
+			}
+			respondWithJSON(w, http.StatusOK, conversation)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	conversation, err := q.CreateConversation(r.Context(), database.CreateConversationParams{
+		IsGroup:   isGroup,
+		DirectKey: key,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+
+	for _, id := range members {
+		err = q.AddConversationParticipant(r.Context(), database.AddConversationParticipantParams{
+			ConversationID: conversation.ID,
+			UserID:         id,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+
+	resp, err := cfg.conversationResponse(r.Context(), conversation, 0)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load conversation", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) conversationResponse(ctx context.Context, c database.Conversation, unread int64) (Conversation, error) {
+	participants, err := cfg.dbQueries.ListConversationParticipants(ctx, c.ID)
+	if err != nil {
+		return Conversation{}, err
+	}
+
+	conversation := Conversation{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt.Time,
+		UpdatedAt:    c.UpdatedAt,
+		IsGroup:      c.IsGroup,
+		Participants: []Participant{},
+		UnreadCount:  unread,
+	}
+	for _, p := range participants {
+		conversation.Participants = append(conversation.Participants, participantFromDB(p))
+	}
+	return conversation, nil
+}
+
+func (cfg *apiConfig) handlerConversations(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	conversations, err := cfg.dbQueries.ListConversations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list conversations", err)
+		return
+	}
+
+	participants, err := cfg.dbQueries.ListParticipantsOfUserConversations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list conversations", err)
+		return
+	}
+
+	byConversation := map[uuid.UUID][]Participant{}
+	for _, p := range participants {
+		byConversation[p.ConversationID] = append(byConversation[p.ConversationID], participantFromDB(p))
+	}
+
+	resp := []Conversation{}
+	for _, c := range conversations {
+		resp = append(resp, Conversation{
+			ID:           c.ID,
+			CreatedAt:    c.CreatedAt.Time,
+			UpdatedAt:    c.UpdatedAt,
+			IsGroup:      c.IsGroup,
+			Participants: byConversation[c.ID],
+			UnreadCount:  c.UnreadCount,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerConversationMessages(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Messages   []Message `json:"messages"`
+		NextCursor string    `json:"next_cursor,omitempty"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	conversation, ok := cfg.participantConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	params := database.ListMessagesParams{
+		ConversationID: conversation.ID,
+		MaxMessages:    int32(limit),
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		beforeAt, beforeID, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		params.BeforeAt = sql.NullTime{Time: beforeAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: beforeID, Valid: true}
+	}
+
+	messages, err := cfg.dbQueries.ListMessages(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list messages", err)
+		return
+	}
+
+	resp := response{
+		Messages: []Message{},
+	}
+	for _, m := range messages {
+		resp.Messages = append(resp.Messages, messageFromDB(m))
+	}
+	if len(messages) == limit {
+		last := messages[len(messages)-1]
+		resp.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerMessagesCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	conversation, ok := cfg.participantConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Body == "" || len(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message must be between 1 and 2000 characters", nil)
+		return
+	}
+
+	// a 1:1 thread stays subject to whatever the other side allows now, and
+	// nobody in a group can reach someone blocked either way
+	participants, err := cfg.dbQueries.ListConversationParticipants(r.Context(), conversation.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	for _, p := range participants {
+		if p.UserID == userID {
+			continue
+		}
+		if conversation.IsGroup {
+			err = cfg.notBlocked(r.Context(), userID, p.UserID)
+		} else {
+			err = cfg.canMessage(r.Context(), userID, p.UserID)
+		}
+		if errors.Is(err, errCannotMessage) {
+			respondWithError(w, http.StatusForbidden, "You can't message this user", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	message, err := q.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversation.ID,
+		SenderID:       userID,
+		Body:           params.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	err = q.TouchConversation(r.Context(), conversation.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	// the sender has obviously seen everything up to their own message
+	err = q.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	err = publishToParticipants(r.Context(), q, conversation.ID, userID, realtime.TypeMessage, messageFromDB(message))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, messageFromDB(message))
+}
+
+// senderMessage loads the message from the path and checks userID wrote it
+func (cfg *apiConfig) senderMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Message, bool) {
+	conversation, ok := cfg.participantConversation(w, r, userID)
+	if !ok {
+		return database.Message{}, false
+	}
+
+	messageUUID, err := uuid.Parse(r.PathValue("messageID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid message id", err)
+		return database.Message{}, false
+	}
+
+	message, err := cfg.dbQueries.GetMessage(r.Context(), database.GetMessageParams{
+		ID:             messageUUID,
+		ConversationID: conversation.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find message", err)
+		return database.Message{}, false
+	}
+
+	if message.SenderID != userID {
+		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+		return database.Message{}, false
+	}
+	if message.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find message", nil)
+		return database.Message{}, false
+	}
+	return message, true
+}
+
+func (cfg *apiConfig) handlerMessageUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	message, ok := cfg.senderMessage(w, r, userID)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Body == "" || len(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message must be between 1 and 2000 characters", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update message", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	message, err = q.UpdateMessage(r.Context(), database.UpdateMessageParams{
+		ID:   message.ID,
+		Body: params.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find message", err)
+		return
+	}
+
+	err = publishToParticipants(r.Context(), q, message.ConversationID, userID, realtime.TypeMessage, messageFromDB(message))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update message", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, messageFromDB(message))
+}
+
+func (cfg *apiConfig) handlerMessageDelete(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	message, ok := cfg.senderMessage(w, r, userID)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete message", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	// the row stays as a tombstone so the thread keeps its shape
+	message, err = q.DeleteMessage(r.Context(), message.ID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find message", err)
+		return
+	}
+
+	err = publishToParticipants(r.Context(), q, message.ConversationID, userID, realtime.TypeMessage, messageFromDB(message))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete message", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete message", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerConversationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	conversation, ok := cfg.participantConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation read", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	err = q.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation read", err)
+		return
+	}
+
+	err = publishToParticipants(r.Context(), q, conversation.ID, userID, realtime.TypeReadReceipt, struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+		UserID         uuid.UUID `json:"user_id"`
+		ReadAt         time.Time `json:"read_at"`
+	}{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		ReadAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation read", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
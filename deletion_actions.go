@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/pagination"
+	"github.com/Weso1ek/chirpy/internal/stream"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// deleted chirps and accounts can be restored for this long before the
+// purge task removes them
+const restoreWindow = 30 * 24 * time.Hour
+
+// restoreCutoff is the oldest deletion that can still be undone
+func restoreCutoff() time.Time {
+	return time.Now().UTC().Add(-restoreWindow)
+}
+
+var errAccountDeleted = errors.New("account is deleted")
+
+// liveUser returns errAccountDeleted unless userID is an account that
+// still exists and isn't deleted
+func (cfg *apiConfig) liveUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := cfg.dbQueries.GetUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errAccountDeleted
+	}
+	return err
+}
+
+// middlewareLiveUser turns away access tokens of deleted accounts, they'd
+// stay valid until they expire otherwise. Anything that isn't a valid
+// access token is left to the handler.
+func (cfg *apiConfig) middlewareLiveUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID, err := auth.ValidateJWT(token, cfg.secret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err = cfg.liveUser(r.Context(), userID)
+		if errors.Is(err, errAccountDeleted) {
+			respondWithError(w, http.StatusUnauthorized, "Account is deleted", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't validate JWT", err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) handlerDeletedChirps(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	params := database.ListDeletedChirpsParams{
+		UserID:    userID,
+		Cutoff:    restoreCutoff(),
+		MaxChirps: int32(limit),
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		beforeAt, beforeID, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		params.BeforeAt = sql.NullTime{Time: beforeAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: beforeID, Valid: true}
+	}
+
+	list, err := cfg.dbQueries.ListDeletedChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list deleted chirps", err)
+		return
+	}
+
+	resp := response{
+		Chirps: []Chirp{},
+	}
+	for _, chirp := range list {
+		resp.Chirps = append(resp.Chirps, chirpFromDB(chirp))
+	}
+	err = cfg.loadChirpDetails(r.Context(), cfg.dbQueries, uuid.NullUUID{UUID: userID, Valid: true}, resp.Chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list deleted chirps", err)
+		return
+	}
+	if len(list) == limit {
+		last := list[len(list)-1]
+		resp.NextCursor = pagination.EncodeCursor(last.DeletedAt.Time, last.ID)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerChirpRestore(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	chirp, err := q.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:     chirpUUID,
+		UserID: userID,
+		Cutoff: restoreCutoff(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find deleted chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	details := []Chirp{chirpFromDB(chirp)}
+	err = cfg.loadChirpDetails(r.Context(), q, uuid.NullUUID{}, details)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+	resp := details[0]
+
+	// subscribers dropped it on the delete event, it comes back like a new
+	// chirp
+	err = publishChirpEvent(r.Context(), q, stream.EventChirpCreated, resp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerUsersDelete deletes the caller's account, it and its chirps are
+// hidden right away and can be restored within restoreWindow
+func (cfg *apiConfig) handlerUsersDelete(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	deleted, err := q.DeleteUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", nil)
+		return
+	}
+
+	// access tokens are turned away by middlewareLiveUser from now on
+	err = q.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerUsersRestore brings a deleted account back and logs it in, the
+// regular login doesn't know about deleted accounts
+func (cfg *apiConfig) handlerUsersRestore(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetDeletedUserByLogin(r.Context(), sql.NullString{String: params.Email, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User not found", nil)
+		return
+	}
+
+	err = auth.CheckPasswordHash(user.HashedPassword.String, params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User not found", nil)
+		return
+	}
+
+	restored, err := cfg.dbQueries.RestoreUser(r.Context(), database.RestoreUserParams{
+		ID:     user.ID,
+		Cutoff: restoreCutoff(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusGone, "Account can no longer be restored", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore account", err)
+		return
+	}
+
+	cfg.respondWithSession(w, r, restored)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/notifications"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FollowRequest struct {
+	RequesterID uuid.UUID `json:"requester_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// requestFollow asks a private account to approve userID as a follower
+func (cfg *apiConfig) requestFollow(w http.ResponseWriter, r *http.Request, userID, targetID uuid.UUID) {
+	type response struct {
+		Status string `json:"status"`
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't request follow", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	requested, err := q.CreateFollowRequest(r.Context(), database.CreateFollowRequestParams{
+		RequesterID: userID,
+		TargetID:    targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't request follow", err)
+		return
+	}
+
+	// asking twice doesn't notify again
+	if requested > 0 {
+		err = notify(r.Context(), q, newNotification{
+			UserID:  targetID,
+			Type:    notifications.TypeFollowRequest,
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+			Subject: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't request follow", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't request follow", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		Status: "requested",
+	})
+}
+
+func (cfg *apiConfig) handlerFollowRequests(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	requests, err := cfg.dbQueries.ListFollowRequests(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list follow requests", err)
+		return
+	}
+
+	resp := []FollowRequest{}
+	for _, request := range requests {
+		resp = append(resp, FollowRequest{
+			RequesterID: request.RequesterID,
+			CreatedAt:   request.CreatedAt.Time,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerFollowRequestAccept(w http.ResponseWriter, r *http.Request) {
+	userID, requesterID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	blocked, err := cfg.isBlocked(r.Context(), userID, requesterID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't accept this request", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	deleted, err := q.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+		RequesterID: requesterID,
+		TargetID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find follow request", nil)
+		return
+	}
+
+	followed, err := q.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: requesterID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+		return
+	}
+
+	if followed > 0 {
+		err = publishFollowed(r.Context(), q, requesterID, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+			return
+		}
+
+		err = notify(r.Context(), q, newNotification{
+			UserID:  requesterID,
+			Type:    notifications.TypeFollowAccepted,
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+			Subject: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't accept follow request", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerFollowRequestReject(w http.ResponseWriter, r *http.Request) {
+	userID, requesterID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	// the requester isn't told, so a rejection looks like a pending request
+	deleted, err := cfg.dbQueries.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+		RequesterID: requesterID,
+		TargetID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reject follow request", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find follow request", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUsersPrivacy(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IsPrivate bool `json:"is_private"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update privacy", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	user, err := q.SetUserPrivate(r.Context(), database.SetUserPrivateParams{
+		ID:        userID,
+		IsPrivate: params.IsPrivate,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update privacy", err)
+		return
+	}
+
+	// going public lets everyone waiting in
+	if !user.IsPrivate {
+		_, err = q.AcceptAllFollowRequests(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update privacy", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update privacy", err)
+		return
+	}
+
+	userResp, err := cfg.userFromDB(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load subscription", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userResp)
+}
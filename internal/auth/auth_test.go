@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,7 +56,7 @@ func TestCheckPasswordHash(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := CheckPasswordHash(tt.password, tt.hash)
+			err := CheckPasswordHash(tt.hash, tt.password)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckPasswordHash() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -154,3 +156,165 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, _ := MakeRefreshToken()
+
+	if HashToken(token) != HashToken(token) {
+		t.Errorf("HashToken() is not deterministic")
+	}
+	if HashToken(token) == token {
+		t.Errorf("HashToken() returned the raw token")
+	}
+	if !CompareTokens(HashToken(token), HashToken(token)) {
+		t.Errorf("CompareTokens() rejected equal hashes")
+	}
+	if CompareTokens(HashToken(token), HashToken("other")) {
+		t.Errorf("CompareTokens() accepted different hashes")
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+	timestamp := "1700000000"
+	signature := SignWebhook("new_secret", timestamp, body)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+		secrets   []string
+		wantErr   error
+	}{
+		{
+			name:      "Valid signature",
+			body:      body,
+			timestamp: timestamp,
+			signature: signature,
+			secrets:   []string{"new_secret"},
+			wantErr:   nil,
+		},
+		{
+			name:      "Valid signature during rotation",
+			body:      body,
+			timestamp: timestamp,
+			signature: "v1=" + signature,
+			secrets:   []string{"old_secret", "new_secret"},
+			wantErr:   nil,
+		},
+		{
+			name:      "Wrong secret",
+			body:      body,
+			timestamp: timestamp,
+			signature: signature,
+			secrets:   []string{"old_secret"},
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "Tampered body",
+			body:      []byte(`{"event":"user.downgraded"}`),
+			timestamp: timestamp,
+			signature: signature,
+			secrets:   []string{"new_secret"},
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "Stale timestamp",
+			body:      body,
+			timestamp: "1699999000",
+			signature: SignWebhook("new_secret", "1699999000", body),
+			secrets:   []string{"new_secret"},
+			wantErr:   ErrStaleTimestamp,
+		},
+		{
+			name:      "Malformed timestamp",
+			body:      body,
+			timestamp: "yesterday",
+			signature: signature,
+			secrets:   []string{"new_secret"},
+			wantErr:   ErrStaleTimestamp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.body, tt.timestamp, tt.signature, tt.secrets, 5*time.Minute, now)
+			if err != tt.wantErr {
+				t.Errorf("VerifyWebhookSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp := "1700000000"
+	signature := SignWebhook("secret", timestamp, []byte(`{"event":"user.upgraded"}`))
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		now       time.Time
+		wantErr   error
+	}{
+		{
+			name:      "Same signature",
+			timestamp: timestamp,
+			signature: signature,
+			now:       now,
+			wantErr:   ErrReplayedRequest,
+		},
+		{
+			name:      "Prefixed signature",
+			timestamp: timestamp,
+			signature: "v1=" + signature,
+			now:       now,
+			wantErr:   ErrReplayedRequest,
+		},
+		{
+			name:      "Upper case signature",
+			timestamp: timestamp,
+			signature: strings.ToUpper(signature),
+			now:       now,
+			wantErr:   ErrReplayedRequest,
+		},
+		{
+			name:      "Other timestamp",
+			timestamp: "1700000001",
+			signature: signature,
+			now:       now,
+			wantErr:   nil,
+		},
+		{
+			name:      "Malformed signature",
+			timestamp: timestamp,
+			signature: "not-hex",
+			now:       now,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "After the window",
+			timestamp: timestamp,
+			signature: signature,
+			now:       now.Add(11 * time.Minute),
+			wantErr:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewReplayCache(5 * time.Minute)
+			err := cache.Check(timestamp, signature, now)
+			if err != nil {
+				t.Fatalf("Check() first delivery error = %v", err)
+			}
+			err = cache.Check(tt.timestamp, tt.signature, tt.now)
+			if err != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
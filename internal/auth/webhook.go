@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature -
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleTimestamp -
+	ErrStaleTimestamp = errors.New("webhook timestamp outside tolerance window")
+	// ErrReplayedRequest -
+	ErrReplayedRequest = errors.New("webhook request already seen")
+)
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of "timestamp.body"
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks that signature was produced by one of
+// secrets over body and that timestamp (unix seconds) is within tolerance
+// of now. Several secrets may be active at once so they can be rotated.
+func VerifyWebhookSignature(body []byte, timestamp, signature string, secrets []string, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff < -tolerance || diff > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "v1="))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	valid := false
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		want, _ := hex.DecodeString(SignWebhook(secret, timestamp, body))
+		// keep checking every secret so timing doesn't reveal which one matched
+		if hmac.Equal(got, want) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ReplayKey identifies a signed delivery by its timestamp and decoded MAC,
+// so the same signature spelled with or without "v1=" or in another hex
+// case is still the same delivery
+func ReplayKey(timestamp, signature string) (string, error) {
+	mac, err := hex.DecodeString(strings.TrimPrefix(signature, "v1="))
+	if err != nil || len(mac) == 0 {
+		return "", ErrInvalidSignature
+	}
+	return timestamp + "." + hex.EncodeToString(mac), nil
+}
+
+// ReplayCache remembers signatures for the tolerance window so a captured
+// request can't be delivered twice
+type ReplayCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time
+}
+
+// NewReplayCache -
+func NewReplayCache(ttl time.Duration) *ReplayCache {
+	return &ReplayCache{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Check records the delivery and returns ErrReplayedRequest if it was
+// already seen inside the window
+func (c *ReplayCache) Check(timestamp, signature string, now time.Time) error {
+	key, err := ReplayKey(timestamp, signature)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for seen, expires := range c.seen {
+		if now.After(expires) {
+			delete(c.seen, seen)
+		}
+	}
+
+	if _, ok := c.seen[key]; ok {
+		return ErrReplayedRequest
+	}
+	c.seen[key] = now.Add(c.Retention())
+	return nil
+}
+
+// Retention is how long a delivery has to be remembered, a timestamp may be
+// up to ttl in the future so it's twice the window
+func (c *ReplayCache) Retention() time.Duration {
+	return 2 * c.ttl
+}
@@ -7,23 +7,68 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const chirpReadableBy = `-- name: ChirpReadableBy :one
+SELECT COALESCE(chirp_readable_by(chirps, $1::uuid), false)::bool AS readable
+FROM chirps
+WHERE id = $2
+`
+
+type ChirpReadableByParams struct {
+	ViewerID uuid.NullUUID
+	ID       uuid.UUID
+}
+
+func (q *Queries) ChirpReadableBy(ctx context.Context, arg ChirpReadableByParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpReadableBy, arg.ViewerID, arg.ID)
+	var readable bool
+	err := row.Scan(&readable)
+	return readable, err
+}
+
+const countChirpsByUserSince = `-- name: CountChirpsByUserSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+  AND created_at > $2
+`
+
+type CountChirpsByUserSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt sql.NullTime
+}
+
+func (q *Queries) CountChirpsByUserSince(ctx context.Context, arg CountChirpsByUserSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUserSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, visibility)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	ReplyToID  uuid.NullUUID
+	Visibility string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.Visibility,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -31,12 +76,17 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1
+UPDATE chirps SET deleted_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
@@ -45,8 +95,9 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at FROM chirps
 WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -58,12 +109,64 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getChirpForViewer = `-- name: GetChirpForViewer :one
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at FROM chirps
+WHERE id = $1
+  AND chirp_visible_to(chirps, $2::uuid)
+`
+
+type GetChirpForViewerParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirpForViewer(ctx context.Context, arg GetChirpForViewerParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForViewer, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getChirpWithDeleted = `-- name: GetChirpWithDeleted :one
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at FROM chirps
+WHERE id = $1
+`
+
+func (q *Queries) GetChirpWithDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpWithDeleted, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const listChirps = `-- name: ListChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -82,6 +185,86 @@ func (q *Queries) ListChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsForViewer = `-- name: ListChirpsForViewer :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at FROM chirps
+WHERE chirp_visible_to(chirps, $1::uuid)
+  AND ($2::uuid IS NULL OR chirps.user_id = $2::uuid)
+  -- an author's pinned chirps are listed ahead of the first page instead
+  AND NOT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE pinned_chirps.chirp_id = chirps.id
+      AND pinned_chirps.user_id = $2::uuid
+  )
+  AND (
+    chirps.visibility <> 'unlisted'
+    OR $2::uuid IS NOT NULL
+    OR chirps.user_id = $1::uuid
+  )
+  AND (
+    $3::timestamp IS NULL
+    OR ($4::bool AND (chirps.created_at, chirps.id) < ($3::timestamp, $5::uuid))
+    OR (NOT $4::bool AND (chirps.created_at, chirps.id) > ($3::timestamp, $5::uuid))
+  )
+ORDER BY
+  CASE WHEN $4::bool THEN created_at END DESC,
+  CASE WHEN $4::bool THEN id END DESC,
+  created_at ASC,
+  id ASC
+-- NULL lists everything, the unpaginated listing
+LIMIT $6
+`
+
+type ListChirpsForViewerParams struct {
+	ViewerID    uuid.NullUUID
+	AuthorID    uuid.NullUUID
+	AfterAt     sql.NullTime
+	NewestFirst bool
+	AfterID     uuid.NullUUID
+	MaxChirps   sql.NullInt32
+}
+
+func (q *Queries) ListChirpsForViewer(ctx context.Context, arg ListChirpsForViewerParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsForViewer,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.AfterAt,
+		arg.NewestFirst,
+		arg.AfterID,
+		arg.MaxChirps,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -95,3 +278,134 @@ func (q *Queries) ListChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const listDeletedChirps = `-- name: ListDeletedChirps :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at FROM chirps
+WHERE user_id = $1
+  AND deleted_at > $2::timestamp
+  AND (
+    $3::timestamp IS NULL
+    OR (deleted_at, id) < ($3::timestamp, $4::uuid)
+  )
+ORDER BY deleted_at DESC, id DESC
+LIMIT $5
+`
+
+type ListDeletedChirpsParams struct {
+	UserID    uuid.UUID
+	Cutoff    time.Time
+	BeforeAt  sql.NullTime
+	BeforeID  uuid.NullUUID
+	MaxChirps int32
+}
+
+func (q *Queries) ListDeletedChirps(ctx context.Context, arg ListDeletedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listDeletedChirps,
+		arg.UserID,
+		arg.Cutoff,
+		arg.BeforeAt,
+		arg.BeforeID,
+		arg.MaxChirps,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps SET deleted_at = NULL
+WHERE id = $1
+  AND user_id = $2
+  AND deleted_at > $3::timestamp
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at
+`
+
+type RestoreChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Cutoff time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.Cutoff)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps SET body = $2,
+visibility = $3,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at
+`
+
+type UpdateChirpParams struct {
+	ID         uuid.UUID
+	Body       string
+	Visibility string
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp, arg.ID, arg.Body, arg.Visibility)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+	)
+	return i, err
+}
@@ -6,16 +6,225 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	FolderID  uuid.NullUUID
+	CreatedAt time.Time
+}
+
+type BookmarkFolder struct {
+	ID        uuid.UUID
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
+	UserID    uuid.UUID
+	Name      string
+}
+
 type Chirp struct {
+	ID         uuid.UUID
+	CreatedAt  sql.NullTime
+	UpdatedAt  sql.NullTime
+	Body       string
+	UserID     uuid.UUID
+	ReplyToID  uuid.NullUUID
+	Visibility string
+	DeletedAt  sql.NullTime
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt sql.NullTime
+}
+
+type ChirpMedium struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type Conversation struct {
 	ID        uuid.UUID
 	CreatedAt sql.NullTime
+	UpdatedAt time.Time
+	IsGroup   bool
+	DirectKey sql.NullString
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       sql.NullTime
+	LastReadAt     sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  sql.NullTime
+}
+
+type FollowRequest struct {
+	RequesterID uuid.UUID
+	TargetID    uuid.UUID
+	CreatedAt   sql.NullTime
+}
+
+type HandleRedirect struct {
+	OldHandle string
+	UserID    uuid.UUID
+	CreatedAt sql.NullTime
+	ExpiresAt time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedUntil sql.NullTime
+	LastError   sql.NullString
+	FinishedAt  sql.NullTime
+}
+
+type MagicLink struct {
+	TokenHash string
+	CreatedAt sql.NullTime
+	UserID    uuid.UUID
+	NonceHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type MediaVariant struct {
+	MediaID     uuid.UUID
+	Name        string
+	StorageKey  string
+	ContentType string
+	Width       int32
+	Height      int32
+}
+
+type Medium struct {
+	ID          uuid.UUID
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+	UserID      uuid.UUID
+	StorageKey  string
+	ContentType string
+	SizeBytes   int64
+	AltText     string
+	Status      string
+	Width       int32
+	Height      int32
+	Blurhash    string
+	Failure     string
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      sql.NullTime
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	EditedAt       sql.NullTime
+	DeletedAt      sql.NullTime
+}
+
+type MutedWord struct {
+	ID        uuid.UUID
+	CreatedAt sql.NullTime
+	UserID    uuid.UUID
+	Phrase    string
+	Action    string
+	ExpiresAt sql.NullTime
+}
+
+type Notification struct {
+	ID         uuid.UUID
+	CreatedAt  sql.NullTime
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Type       string
+	GroupKey   string
+	ActorID    uuid.NullUUID
+	ActorCount int32
+	ChirpID    uuid.NullUUID
+	Data       json.RawMessage
+	ReadAt     sql.NullTime
+	ActorIds   []uuid.UUID
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
 	UpdatedAt sql.NullTime
-	Body      string
+}
+
+type OutboxEvent struct {
+	ID           uuid.UUID
+	CreatedAt    sql.NullTime
+	EventType    string
+	Payload      json.RawMessage
+	DispatchedAt sql.NullTime
+	AudienceID   uuid.NullUUID
+	RecipientIds []uuid.UUID
+	SubjectID    uuid.NullUUID
+}
+
+type PinnedChirp struct {
+	UserID   uuid.UUID
+	ChirpID  uuid.UUID
+	PinnedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt sql.NullTime
+	Multiple  bool
+	ClosesAt  time.Time
+	ClosedAt  sql.NullTime
+}
+
+type PollOption struct {
+	ChirpID    uuid.UUID
+	Position   int32
+	Text       string
+	FinalVotes sql.NullInt64
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
 	UserID    uuid.UUID
+	Choices   []int32
+	CreatedAt sql.NullTime
+}
+
+type RealtimeMessage struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Topic     string
+	Type      string
+	Data      json.RawMessage
 }
 
 type RefreshToken struct {
@@ -27,11 +236,113 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type ScheduledChirp struct {
+	ID         uuid.UUID
+	CreatedAt  sql.NullTime
+	UpdatedAt  sql.NullTime
+	UserID     uuid.UUID
+	Body       string
+	ReplyToID  uuid.NullUUID
+	Visibility string
+	Mentions   []uuid.UUID
+	MediaIds   []uuid.UUID
+	PublishAt  sql.NullTime
+	Status     string
+	Failure    string
+	Poll       json.RawMessage
+}
+
+type ScheduledTask struct {
+	Name      string
+	LastRunAt time.Time
+}
+
+type Subscription struct {
+	ID             uuid.UUID
+	CreatedAt      sql.NullTime
+	UpdatedAt      sql.NullTime
+	UserID         uuid.UUID
+	Plan           string
+	Status         string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	WebhookEventID uuid.NullUUID
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      sql.NullTime
 	UpdatedAt      sql.NullTime
 	Email          sql.NullString
 	HashedPassword sql.NullString
-	IsChirpyRed    sql.NullBool
+	IsPrivate      bool
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarMediaID  uuid.NullUUID
+	Website        string
+	DeletedAt      sql.NullTime
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt sql.NullTime
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt sql.NullTime
+}
+
+type UserStat struct {
+	UserID        uuid.UUID
+	FollowerCount int64
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      sql.NullTime
+	UpdatedAt      sql.NullTime
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
+}
+
+type WebhookSignature struct {
+	Provider  string
+	ReplayKey string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
+	UserID    uuid.UUID
+	Url       string
+	Events    []string
+	Secret    string
+	Active    bool
 }
@@ -8,14 +8,16 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at
 `
 
 type CreateUserParams struct {
@@ -32,14 +34,118 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+UPDATE users SET deleted_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDeletedUserByLogin = `-- name: GetDeletedUserByLogin :one
+SELECT id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at FROM users
+WHERE email = $1
+  AND deleted_at IS NOT NULL
+LIMIT 1
+`
+
+func (q *Queries) GetDeletedUserByLogin(ctx context.Context, email sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUserByLogin, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+LIMIT 1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at FROM users
+WHERE LOWER(handle) = LOWER($1)
+  AND deleted_at IS NULL
+LIMIT 1
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserByLogin = `-- name: GetUserByLogin :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
-WHERE email = $1 LIMIT 1
+SELECT id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at FROM users
+WHERE email = $1
+  AND deleted_at IS NULL
+LIMIT 1
 `
 
 func (q *Queries) GetUserByLogin(ctx context.Context, email sql.NullString) (User, error) {
@@ -51,15 +157,141 @@ func (q *Queries) GetUserByLogin(ctx context.Context, email sql.NullString) (Use
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const listUserIDsByHandles = `-- name: ListUserIDsByHandles :many
+SELECT id FROM users
+WHERE LOWER(handle) = ANY($1::text[])
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) ListUserIDsByHandles(ctx context.Context, handles []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listUserIDsByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUser = `-- name: LockUser :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at <= $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users SET deleted_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+  AND deleted_at > $2::timestamp
+RETURNING id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at
+`
+
+type RestoreUserParams struct {
+	ID     uuid.UUID
+	Cutoff time.Time
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.Cutoff)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserPrivate = `-- name: SetUserPrivate :one
+UPDATE users SET is_private = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at
+`
+
+type SetUserPrivateParams struct {
+	ID        uuid.UUID
+	IsPrivate bool
+}
+
+func (q *Queries) SetUserPrivate(ctx context.Context, arg SetUserPrivateParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPrivate, arg.ID, arg.IsPrivate)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
-UPDATE users SET email = $1, hashed_password = $2
+UPDATE users SET email = COALESCE($1, email),
+hashed_password = COALESCE($2, hashed_password),
+updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at
 `
 
 type UpdateUserParams struct {
@@ -77,19 +309,46 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const updateUserRed = `-- name: UpdateUserRed :one
-UPDATE users SET is_chirpy_red = TRUE
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users SET handle = $2,
+display_name = $3,
+bio = $4,
+avatar_media_id = $5,
+website = $6,
+updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_private, handle, display_name, bio, avatar_media_id, website, deleted_at
 `
 
-func (q *Queries) UpdateUserRed(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserRed, id)
+type UpdateUserProfileParams struct {
+	ID            uuid.UUID
+	Handle        sql.NullString
+	DisplayName   string
+	Bio           string
+	AvatarMediaID uuid.NullUUID
+	Website       string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarMediaID,
+		arg.Website,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -97,7 +356,13 @@ func (q *Queries) UpdateUserRed(ctx context.Context, id uuid.UUID) (User, error)
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsPrivate,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.Website,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,123 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration that reads "1h30m" style strings from JSON
+type Duration time.Duration
+
+// UnmarshalJSON -
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON -
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Limits are the answers for a single plan. A zero rate limit means
+// unlimited, a zero edit window means chirps can't be edited.
+type Limits struct {
+	MaxChirpLength int      `json:"max_chirp_length"`
+	EditWindow     Duration `json:"edit_window"`
+	MediaPerChirp  int      `json:"media_per_chirp"`
+	ChirpsPerHour  int      `json:"chirps_per_hour"`
+	PinnedChirps   int      `json:"pinned_chirps"`
+}
+
+// Engine answers what a plan is entitled to
+type Engine struct {
+	plans    map[string]Limits
+	fallback string
+}
+
+// Defaults returns the built-in limits for the free and red plans
+func Defaults() map[string]Limits {
+	return map[string]Limits{
+		"free": {
+			MaxChirpLength: 140,
+			EditWindow:     0,
+			MediaPerChirp:  1,
+			ChirpsPerHour:  30,
+			PinnedChirps:   1,
+		},
+		"red": {
+			MaxChirpLength: 500,
+			EditWindow:     Duration(time.Hour),
+			MediaPerChirp:  4,
+			ChirpsPerHour:  0,
+			PinnedChirps:   5,
+		},
+	}
+}
+
+// New returns an engine for plans, unknown plans get the fallback limits
+func New(plans map[string]Limits, fallback string) (*Engine, error) {
+	if _, ok := plans[fallback]; !ok {
+		return nil, fmt.Errorf("fallback plan %q is not configured", fallback)
+	}
+	return &Engine{
+		plans:    plans,
+		fallback: fallback,
+	}, nil
+}
+
+// Load reads per plan overrides from a JSON file on top of Defaults.
+// An empty path returns the defaults unchanged.
+func Load(path string) (map[string]Limits, error) {
+	plans := Defaults()
+	if path == "" {
+		return plans, nil
+	}
+
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entitlements: %w", err)
+	}
+
+	// decode into copies of the defaults so omitted fields keep their value
+	overrides := map[string]json.RawMessage{}
+	err = json.Unmarshal(dat, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse entitlements: %w", err)
+	}
+	for plan, raw := range overrides {
+		limits := plans[plan]
+		err = json.Unmarshal(raw, &limits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse entitlements for %s: %w", plan, err)
+		}
+		plans[plan] = limits
+	}
+
+	return plans, nil
+}
+
+// For returns the limits of plan
+func (e *Engine) For(plan string) Limits {
+	limits, ok := e.plans[plan]
+	if !ok {
+		return e.plans[e.fallback]
+	}
+	return limits
+}
+
+// CanEdit reports whether a chirp created at createdAt may still be edited
+func (l Limits) CanEdit(createdAt, now time.Time) bool {
+	return now.Sub(createdAt) <= time.Duration(l.EditWindow)
+}
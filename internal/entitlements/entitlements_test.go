@@ -0,0 +1,55 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entitlements.json")
+	err := os.WriteFile(path, []byte(`{"red": {"max_chirp_length": 1000, "edit_window": "30m"}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plans, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	engine, err := New(plans, "free")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	red := engine.For("red")
+	if red.MaxChirpLength != 1000 {
+		t.Errorf("MaxChirpLength = %d, want 1000", red.MaxChirpLength)
+	}
+	if time.Duration(red.EditWindow) != 30*time.Minute {
+		t.Errorf("EditWindow = %v, want 30m", time.Duration(red.EditWindow))
+	}
+	if red.MediaPerChirp != Defaults()["red"].MediaPerChirp {
+		t.Errorf("MediaPerChirp = %d, want default to be kept", red.MediaPerChirp)
+	}
+	if engine.For("unknown") != engine.For("free") {
+		t.Errorf("For() unknown plan didn't fall back to free")
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	now := time.Now()
+	limits := Limits{EditWindow: Duration(time.Hour)}
+
+	if !limits.CanEdit(now.Add(-time.Minute), now) {
+		t.Errorf("CanEdit() rejected an edit inside the window")
+	}
+	if limits.CanEdit(now.Add(-2*time.Hour), now) {
+		t.Errorf("CanEdit() accepted an edit outside the window")
+	}
+	if (Limits{}).CanEdit(now.Add(-time.Second), now) {
+		t.Errorf("CanEdit() accepted an edit without a window")
+	}
+}
@@ -0,0 +1,90 @@
+package filters
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// ActionHide drops matching chirps entirely
+	ActionHide = "hide"
+	// ActionWarn keeps matching chirps but collapses them behind a warning
+	ActionWarn = "warn"
+)
+
+// Filter is one muted word, phrase or hashtag
+type Filter struct {
+	Phrase    string
+	Action    string
+	ExpiresAt time.Time
+}
+
+// Active reports whether f still applies at now, a zero ExpiresAt never expires
+func (f Filter) Active(now time.Time) bool {
+	return f.ExpiresAt.IsZero() || now.Before(f.ExpiresAt)
+}
+
+// Result is what a set of filters decided about a chirp
+type Result struct {
+	Action  string
+	Matched []string
+}
+
+// Hidden -
+func (r Result) Hidden() bool {
+	return r.Action == ActionHide
+}
+
+// Match checks body against every active filter. Hide wins over warn when
+// both match.
+func Match(filters []Filter, body string, now time.Time) Result {
+	words := Tokenize(body)
+	result := Result{}
+	for _, f := range filters {
+		if !f.Active(now) || !containsPhrase(words, Tokenize(f.Phrase)) {
+			continue
+		}
+		result.Matched = append(result.Matched, f.Phrase)
+		if result.Action != ActionHide {
+			result.Action = f.Action
+		}
+	}
+	return result
+}
+
+// Tokenize splits text into lower cased words the way ValidateChirp
+// compares them, keeping a leading # so hashtags can be told apart
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '#' && r != '_'
+	})
+}
+
+func containsPhrase(words, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, p := range phrase {
+			if !wordMatches(words[i+j], p) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// wordMatches lets a plain word also catch its hashtag, while a hashtag
+// filter only catches the hashtag
+func wordMatches(word, filter string) bool {
+	if strings.HasPrefix(filter, "#") {
+		return word == filter
+	}
+	return strings.TrimPrefix(word, "#") == filter
+}
@@ -0,0 +1,79 @@
+package filters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filters []Filter
+		body    string
+		want    Result
+	}{
+		{
+			name:    "word ignores case and punctuation",
+			filters: []Filter{{Phrase: "kerfuffle", Action: ActionHide}},
+			body:    "What a Kerfuffle!",
+			want:    Result{Action: ActionHide, Matched: []string{"kerfuffle"}},
+		},
+		{
+			name:    "word doesn't match inside another word",
+			filters: []Filter{{Phrase: "cat", Action: ActionHide}},
+			body:    "concatenate",
+			want:    Result{},
+		},
+		{
+			name:    "word catches its hashtag",
+			filters: []Filter{{Phrase: "spoilers", Action: ActionWarn}},
+			body:    "no #spoilers please",
+			want:    Result{Action: ActionWarn, Matched: []string{"spoilers"}},
+		},
+		{
+			name:    "hashtag only catches the hashtag",
+			filters: []Filter{{Phrase: "#spoilers", Action: ActionHide}},
+			body:    "no spoilers please",
+			want:    Result{},
+		},
+		{
+			name:    "phrase needs consecutive words",
+			filters: []Filter{{Phrase: "season finale", Action: ActionWarn}},
+			body:    "the Season  Finale was wild",
+			want:    Result{Action: ActionWarn, Matched: []string{"season finale"}},
+		},
+		{
+			name:    "phrase words apart don't match",
+			filters: []Filter{{Phrase: "season finale", Action: ActionWarn}},
+			body:    "season two finale",
+			want:    Result{},
+		},
+		{
+			name:    "expired filter is ignored",
+			filters: []Filter{{Phrase: "election", Action: ActionHide, ExpiresAt: now.Add(-time.Minute)}},
+			body:    "election day",
+			want:    Result{},
+		},
+		{
+			name: "hide wins over warn",
+			filters: []Filter{
+				{Phrase: "election", Action: ActionHide, ExpiresAt: now.Add(time.Hour)},
+				{Phrase: "day", Action: ActionWarn},
+			},
+			body: "election day",
+			want: Result{Action: ActionHide, Matched: []string{"election", "day"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Match(tt.filters, tt.body, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
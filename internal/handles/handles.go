@@ -0,0 +1,49 @@
+package handles
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	validHandle = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+	// an @ preceded by a word character is an email address, not a mention
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@/])@(\w+)`)
+	// handles that would collide with routes under /api/users
+	reserved = map[string]bool{
+		"search":  true,
+		"privacy": true,
+		"restore": true,
+	}
+)
+
+// Normalize strips a leading @ so clients can send either form
+func Normalize(handle string) string {
+	return strings.TrimPrefix(strings.TrimSpace(handle), "@")
+}
+
+// Valid reports whether handle is 3 to 30 letters, digits or underscores
+func Valid(handle string) bool {
+	return validHandle.MatchString(handle)
+}
+
+// Reserved reports whether handle is kept back from users
+func Reserved(handle string) bool {
+	return reserved[strings.ToLower(handle)]
+}
+
+// Mentions returns the distinct handles mentioned in body, lowercased
+// since handles are matched without case
+func Mentions(body string) []string {
+	var mentions []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(m[1])
+		if !Valid(handle) || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		mentions = append(mentions, handle)
+	}
+	return mentions
+}
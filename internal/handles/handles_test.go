@@ -0,0 +1,73 @@
+package handles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "none", body: "just chirping", want: nil},
+		{name: "start and middle", body: "@alice meet @Bob_2", want: []string{"alice", "bob_2"}},
+		{name: "repeats differ in case", body: "@alice and @ALICE", want: []string{"alice"}},
+		{name: "email is not a mention", body: "mail me at carol@example.com", want: nil},
+		{name: "punctuation around", body: "(@dave), @erin!", want: []string{"dave", "erin"}},
+		{name: "too short", body: "@ab", want: nil},
+		{name: "too long", body: "@abcdefghijklmnopqrstuvwxyz01234", want: nil},
+		{name: "url path", body: "see example.com/@frank", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mentions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   bool
+	}{
+		{handle: "alice", want: true},
+		{handle: "Bob_2", want: true},
+		{handle: "ab", want: false},
+		{handle: "has space", want: false},
+		{handle: "dash-ed", want: false},
+		{handle: "@alice", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			if got := Valid(tt.handle); got != tt.want {
+				t.Errorf("Valid(%q) = %v, want %v", tt.handle, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReserved(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   bool
+	}{
+		{handle: "search", want: true},
+		{handle: "Search", want: true},
+		{handle: "searching", want: false},
+		{handle: "restore", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			if got := Reserved(tt.handle); got != tt.want {
+				t.Errorf("Reserved(%q) = %v, want %v", tt.handle, got, tt.want)
+			}
+		})
+	}
+}
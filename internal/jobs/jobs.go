@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"log"
+	"sync"
+	"time"
+)
+
+// Handler processes the raw payload of one job
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type registration struct {
+	handler Handler
+	slots   chan struct{}
+}
+
+// Queue runs jobs stored in the jobs table. Every replica can run a
+// Queue, rows are claimed with FOR UPDATE SKIP LOCKED and a lease so a
+// job is only picked up again if its worker died.
+type Queue struct {
+	queries  *database.Queries
+	mu       sync.Mutex
+	handlers map[string]registration
+	wg       sync.WaitGroup
+	Interval time.Duration
+	Lease    time.Duration
+}
+
+// New -
+func New(queries *database.Queries) *Queue {
+	return &Queue{
+		queries:  queries,
+		handlers: make(map[string]registration),
+		Interval: time.Second,
+		Lease:    5 * time.Minute,
+	}
+}
+
+// Register adds a handler for kind that runs at most concurrency jobs at once
+func (q *Queue) Register(kind string, concurrency int, handler Handler) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = registration{
+		handler: handler,
+		slots:   make(chan struct{}, concurrency),
+	}
+}
+
+// Handle registers a handler that receives the payload decoded into T
+func Handle[T any](q *Queue, kind string, concurrency int, fn func(ctx context.Context, payload T) error) {
+	q.Register(kind, concurrency, func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		err := json.Unmarshal(raw, &payload)
+		if err != nil {
+			return fmt.Errorf("couldn't decode %s payload: %w", kind, err)
+		}
+		return fn(ctx, payload)
+	})
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int32
+}
+
+// Option changes how a job is enqueued
+type Option func(*enqueueOptions)
+
+// RunAt schedules the job for later
+func RunAt(t time.Time) Option {
+	return func(o *enqueueOptions) {
+		o.runAt = t
+	}
+}
+
+// MaxAttempts sets how often the job is tried before it is marked failed
+func MaxAttempts(n int32) Option {
+	return func(o *enqueueOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// Enqueue stores a job. Pass a Queries from WithTx to enqueue it in the
+// same transaction as the change that needs it.
+func Enqueue(ctx context.Context, queries *database.Queries, kind string, payload any, opts ...Option) (database.Job, error) {
+	options := enqueueOptions{
+		runAt:       time.Now().UTC(),
+		maxAttempts: 10,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return database.Job{}, fmt.Errorf("failed to marshal %s payload: %w", kind, err)
+	}
+
+	return queries.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind,
+		Payload:     dat,
+		MaxAttempts: options.maxAttempts,
+		RunAt:       options.runAt,
+	})
+}
+
+// Backoff returns how long to wait before retrying after attempt failures
+func Backoff(attempt int32) time.Duration {
+	const (
+		base    = 10 * time.Second
+		maxWait = time.Hour
+	)
+	wait := base
+	for i := int32(1); i < attempt; i++ {
+		wait *= 2
+		if wait >= maxWait {
+			return maxWait
+		}
+	}
+	return wait
+}
+
+// Run polls for due jobs until ctx is cancelled, then waits for running
+// jobs to finish
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.Interval)
+	defer ticker.Stop()
+
+	for {
+		q.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			q.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) poll(ctx context.Context) {
+	q.mu.Lock()
+	handlers := make(map[string]registration, len(q.handlers))
+	for kind, reg := range q.handlers {
+		handlers[kind] = reg
+	}
+	q.mu.Unlock()
+
+	for kind, reg := range handlers {
+		free := cap(reg.slots) - len(reg.slots)
+		if free == 0 {
+			continue
+		}
+
+		claimed, err := q.queries.ClaimJobs(ctx, database.ClaimJobsParams{
+			LockedUntil: sql.NullTime{Time: time.Now().UTC().Add(q.Lease), Valid: true},
+			Kind:        kind,
+			MaxJobs:     int32(free),
+		})
+		if err != nil {
+			log.Printf("Couldn't claim %s jobs: %s", kind, err)
+			continue
+		}
+
+		for _, job := range claimed {
+			reg.slots <- struct{}{}
+			q.wg.Add(1)
+			go func() {
+				defer q.wg.Done()
+				defer func() { <-reg.slots }()
+				q.run(ctx, reg.handler, job)
+			}()
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, handler Handler, job database.Job) {
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.Lease)
+	defer cancel()
+
+	errRun := runSafely(context.WithValue(jobCtx, finalAttemptKey{}, job.Attempts >= job.MaxAttempts), handler, job.Payload)
+
+	// the attempt number is the lease: once another worker reclaims the
+	// job after locked_until, these updates match no row
+	var recorded int64
+	var err error
+	switch {
+	case errRun == nil:
+		recorded, err = q.queries.CompleteJob(jobCtx, database.CompleteJobParams{
+			ID:       job.ID,
+			Attempts: job.Attempts,
+		})
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %s (%s) failed permanently: %s", job.ID, job.Kind, errRun)
+		recorded, err = q.queries.FailJob(jobCtx, database.FailJobParams{
+			ID:        job.ID,
+			LastError: sql.NullString{String: errRun.Error(), Valid: true},
+			Attempts:  job.Attempts,
+		})
+	default:
+		recorded, err = q.queries.RetryJob(jobCtx, database.RetryJobParams{
+			ID:        job.ID,
+			RunAt:     time.Now().UTC().Add(Backoff(job.Attempts)),
+			LastError: sql.NullString{String: errRun.Error(), Valid: true},
+			Attempts:  job.Attempts,
+		})
+	}
+	if err != nil {
+		log.Printf("Couldn't record result of job %s: %s", job.ID, err)
+		return
+	}
+	if recorded == 0 {
+		log.Printf("Lost lease on job %s (%s), result of attempt %d discarded", job.ID, job.Kind, job.Attempts)
+	}
+}
+
+type finalAttemptKey struct{}
+
+// FinalAttempt reports whether the job running with ctx won't be retried
+// if it fails, so handlers can record the failure themselves
+func FinalAttempt(ctx context.Context) bool {
+	final, _ := ctx.Value(finalAttemptKey{}).(bool)
+	return final
+}
+
+// runSafely turns a panicking handler into a failed attempt
+func runSafely(ctx context.Context, handler Handler, payload json.RawMessage) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return handler(ctx, payload)
+}
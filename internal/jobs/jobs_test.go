@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int32
+		want    time.Duration
+	}{
+		{attempt: 1, want: 10 * time.Second},
+		{attempt: 2, want: 20 * time.Second},
+		{attempt: 4, want: 80 * time.Second},
+		{attempt: 30, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	type payload struct {
+		Email string `json:"email"`
+	}
+
+	q := New(nil)
+	var got payload
+	Handle(q, "test", 1, func(ctx context.Context, p payload) error {
+		got = p
+		if p.Email == "" {
+			return errors.New("missing email")
+		}
+		return nil
+	})
+
+	handler := q.handlers["test"].handler
+	err := runSafely(context.Background(), handler, json.RawMessage(`{"email":"a@example.com"}`))
+	if err != nil || got.Email != "a@example.com" {
+		t.Errorf("Handle() decoded %+v, err %v", got, err)
+	}
+	if err := runSafely(context.Background(), handler, json.RawMessage(`{}`)); err == nil {
+		t.Errorf("Handle() didn't return the handler error")
+	}
+	if err := runSafely(context.Background(), handler, json.RawMessage(`[`)); err == nil {
+		t.Errorf("Handle() accepted an invalid payload")
+	}
+
+	panicking := func(ctx context.Context, raw json.RawMessage) error { panic("boom") }
+	if err := runSafely(context.Background(), panicking, nil); err == nil {
+		t.Errorf("runSafely() didn't recover from a panic")
+	}
+}
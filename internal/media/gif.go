@@ -0,0 +1,69 @@
+package media
+
+import (
+	"errors"
+)
+
+var errTruncatedGIF = errors.New("truncated gif")
+
+// gifFrames counts the frames of a GIF by walking its blocks, without
+// decoding any of them
+func gifFrames(data []byte) (int, error) {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, errTruncatedGIF
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21:
+			// extension: label, then data sub-blocks
+			end, err := skipSubBlocks(data, i+2)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case 0x2C:
+			// image descriptor, optional local color table, LZW code size
+			if i+10 > len(data) {
+				return 0, errTruncatedGIF
+			}
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			end, err := skipSubBlocks(data, i+1)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+			frames++
+		case 0x3B:
+			return frames, nil
+		default:
+			return 0, errors.New("unknown gif block")
+		}
+	}
+	return 0, errTruncatedGIF
+}
+
+// skipSubBlocks returns the offset after the sub-blocks starting at i
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errTruncatedGIF
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+}
@@ -0,0 +1,28 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// Types maps the image types we accept to the extension they are stored
+// with
+var Types = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Sniff detects the type of body from its first bytes, ignoring whatever
+// the client claimed. The returned reader still yields the whole body.
+func Sniff(body io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), body), nil
+}
@@ -0,0 +1,151 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels caps the decoded size so a small file can't expand into a
+// huge bitmap
+const MaxPixels = 40_000_000
+
+// Variants are the longest edge of each resized copy, images are never
+// scaled up
+var Variants = map[string]int{
+	"thumbnail": 320,
+	"medium":    1280,
+}
+
+// ErrInvalidImage means the upload can't be processed and retrying won't
+// help
+var ErrInvalidImage = errors.New("invalid image")
+
+// Image is one encoded output of Process
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Result is an upload re-encoded without its metadata, its variants and
+// a blurhash placeholder
+type Result struct {
+	Original Image
+	Variants map[string]Image
+	Blurhash string
+}
+
+// Process decodes an uploaded image and re-encodes it. JPEGs stay JPEG,
+// GIFs keep their frames and everything else becomes PNG since there is
+// no pure Go WebP encoder.
+func Process(data []byte) (*Result, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is too large", ErrInvalidImage, config.Width, config.Height)
+	}
+	// every frame of a GIF is decoded when it's re-encoded
+	if format == "gif" {
+		frames, err := gifFrames(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+		}
+		if frames*config.Width*config.Height > MaxPixels {
+			return nil, fmt.Errorf("%w: %d frames of %dx%d is too large", ErrInvalidImage, frames, config.Width, config.Height)
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	encode := encodePNG
+	if format == "jpeg" {
+		encode = encodeJPEG
+	}
+
+	res := &Result{Variants: map[string]Image{}}
+	if format == "gif" {
+		res.Original, err = reencodeGIF(data)
+	} else {
+		res.Original, err = encode(img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name, edge := range Variants {
+		res.Variants[name], err = encode(fit(img, edge))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res.Blurhash = Blurhash(fit(img, 32), 4, 3)
+	return res, nil
+}
+
+// fit scales img down so its longest edge is at most edge
+func fit(img image.Image, edge int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= edge && h <= edge {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*edge/w)
+		w = edge
+	} else {
+		w = max(1, w*edge/h)
+		h = edge
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encodeJPEG(img image.Image) (Image, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+	return Image{Data: buf.Bytes(), ContentType: "image/jpeg", Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+}
+
+func encodePNG(img image.Image) (Image, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to encode png: %w", err)
+	}
+	return Image{Data: buf.Bytes(), ContentType: "image/png", Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+}
+
+func reencodeGIF(data []byte) (Image, error) {
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, anim)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to encode gif: %w", err)
+	}
+	return Image{Data: buf.Bytes(), ContentType: "image/gif", Width: anim.Config.Width, Height: anim.Config.Height}, nil
+}
@@ -0,0 +1,130 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation inserts an EXIF segment into a JPEG right after SOI
+func withOrientation(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	seg := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(seg)+2))
+	out.Write(seg)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2000, 1000))
+	for y := 0; y < 1000; y++ {
+		for x := 0; x < 2000; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	// rotated 90 degrees clockwise
+	data := withOrientation(t, buf.Bytes(), 6)
+
+	res, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if res.Original.Width != 1000 || res.Original.Height != 2000 {
+		t.Errorf("Original is %dx%d, want 1000x2000", res.Original.Width, res.Original.Height)
+	}
+	if bytes.Contains(res.Original.Data, []byte("Exif")) {
+		t.Errorf("Original still has EXIF data")
+	}
+	if thumb := res.Variants["thumbnail"]; thumb.Width != 160 || thumb.Height != 320 {
+		t.Errorf("thumbnail is %dx%d, want 160x320", thumb.Width, thumb.Height)
+	}
+	if medium := res.Variants["medium"]; medium.Width != 640 || medium.Height != 1280 || medium.ContentType != "image/jpeg" {
+		t.Errorf("medium is %dx%d %s, want 640x1280 image/jpeg", medium.Width, medium.Height, medium.ContentType)
+	}
+	if len(res.Blurhash) != 28 {
+		t.Errorf("Blurhash = %q, want 28 characters", res.Blurhash)
+	}
+
+	_, err = Process([]byte("not an image"))
+	if !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Process() garbage error = %v, want ErrInvalidImage", err)
+	}
+}
+
+func TestBlurhashUniform(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+
+	white := base83(0xFFFFFF, 4)
+	if got := Blurhash(img, 1, 1); got != "00"+white {
+		t.Errorf("Blurhash() = %q, want %q", got, "00"+white)
+	}
+
+	got := Blurhash(img, 4, 3)
+	if len(got) != 28 || got[0] != 'L' || got[2:6] != white {
+		t.Errorf("Blurhash() = %q, want 4x3 components with a white average", got)
+	}
+}
+
+// tinyFrames is a GIF with a large canvas drawn by a few 1x1 frames
+func tinyFrames(t *testing.T, frames, size int) []byte {
+	t.Helper()
+	anim := &gif.GIF{
+		Config: image.Config{Width: size, Height: size, ColorModel: color.Palette{color.Black, color.White}},
+	}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White}))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFFrames(t *testing.T) {
+	got, err := gifFrames(tinyFrames(t, 3, 10))
+	if err != nil || got != 3 {
+		t.Errorf("gifFrames() = %d, %v, want 3", got, err)
+	}
+
+	_, err = gifFrames([]byte("GIF89a"))
+	if err == nil {
+		t.Errorf("gifFrames() of a truncated gif succeeded")
+	}
+
+	// the cap assumes the worst case of every frame covering the canvas
+	_, err = Process(tinyFrames(t, 11, 2000))
+	if !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Process() error = %v, want ErrInvalidImage for too many frames", err)
+	}
+}
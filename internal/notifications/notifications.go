@@ -0,0 +1,48 @@
+package notifications
+
+import (
+	"github.com/google/uuid"
+)
+
+const (
+	TypeMention      = "mention"
+	TypeReply        = "reply"
+	TypeFollow       = "follow"
+	TypeLike         = "like"
+	TypeSubscription = "subscription"
+	// TypeFollowRequest asks a private account to approve a follower
+	TypeFollowRequest = "follow_request"
+	// TypeFollowAccepted tells the requester they were approved
+	TypeFollowAccepted = "follow_accepted"
+)
+
+// Types lists every notification type, each can be turned off separately
+var Types = []string{
+	TypeMention,
+	TypeReply,
+	TypeFollow,
+	TypeLike,
+	TypeSubscription,
+	TypeFollowRequest,
+	TypeFollowAccepted,
+}
+
+// Valid reports whether t is a known notification type
+func Valid(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupKey decides which notifications are folded together while unread.
+// Likes group per chirp and follows and follow requests per user; replies
+// and mentions group on the chirp that raised them so each one stays visible.
+func GroupKey(t string, subject uuid.UUID) string {
+	if t == TypeFollow || t == TypeFollowRequest {
+		return t
+	}
+	return t + ":" + subject.String()
+}
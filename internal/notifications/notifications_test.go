@@ -0,0 +1,31 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGroupKey(t *testing.T) {
+	chirpID := uuid.New()
+	tests := []struct {
+		name    string
+		t       string
+		subject uuid.UUID
+		want    string
+	}{
+		{name: "likes group per chirp", t: TypeLike, subject: chirpID, want: "like:" + chirpID.String()},
+		{name: "follows group together", t: TypeFollow, subject: uuid.New(), want: "follow"},
+		{name: "follow requests group together", t: TypeFollowRequest, subject: uuid.New(), want: "follow_request"},
+		{name: "acceptances group per user", t: TypeFollowAccepted, subject: chirpID, want: "follow_accepted:" + chirpID.String()},
+		{name: "replies stay separate", t: TypeReply, subject: chirpID, want: "reply:" + chirpID.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupKey(tt.t, tt.subject); got != tt.want {
+				t.Errorf("GroupKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
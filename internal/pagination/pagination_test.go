@@ -0,0 +1,33 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursor(t *testing.T) {
+	at := time.Date(2025, 4, 1, 12, 30, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	gotAt, gotID, err := DecodeCursor(EncodeCursor(at, id))
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !gotAt.Equal(at) || gotID != id {
+		t.Errorf("DecodeCursor() = %v, %v, want %v, %v", gotAt, gotID, at, id)
+	}
+
+	tests := []string{
+		"",
+		"not base64!",
+		"bm8tY29tbWE",
+		EncodeCursor(at, id)[:10],
+	}
+	for _, cursor := range tests {
+		if _, _, err := DecodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) error = %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package pgnotify
+
+import (
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Listen calls handle with the payload of every NOTIFY on channel until
+// done is closed. It reconnects on its own when the connection drops.
+func Listen(dbURL, channel string, done <-chan struct{}, handle func(payload string)) error {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Listener on %s: %s", channel, err)
+		}
+	})
+	defer listener.Close()
+
+	err := listener.Listen(channel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case n := <-listener.Notify:
+			// nil means the connection was re-established
+			if n == nil {
+				continue
+			}
+			handle(n.Extra)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
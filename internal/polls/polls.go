@@ -0,0 +1,78 @@
+package polls
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	MinOptions      = 2
+	MaxOptions      = 4
+	MaxOptionLength = 50
+	MinDuration     = 5 * time.Minute
+	MaxDuration     = 7 * 24 * time.Hour
+)
+
+var (
+	ErrOptionCount  = errors.New("a poll needs 2 to 4 options")
+	ErrOptionText   = errors.New("poll options must be 1 to 50 characters")
+	ErrDuplicate    = errors.New("poll options must be different")
+	ErrClosesAt     = errors.New("a poll must close between 5 minutes and 7 days after posting")
+	ErrNoChoice     = errors.New("pick at least one option")
+	ErrSingleChoice = errors.New("this poll only takes one choice")
+	ErrUnknown      = errors.New("unknown poll option")
+)
+
+// ValidateOptions checks the option texts of a new poll
+func ValidateOptions(options []string) error {
+	if len(options) < MinOptions || len(options) > MaxOptions {
+		return ErrOptionCount
+	}
+	seen := map[string]bool{}
+	for _, option := range options {
+		text := strings.TrimSpace(option)
+		if text == "" || len(text) > MaxOptionLength {
+			return ErrOptionText
+		}
+		if seen[strings.ToLower(text)] {
+			return ErrDuplicate
+		}
+		seen[strings.ToLower(text)] = true
+	}
+	return nil
+}
+
+// ValidateClosesAt checks the closing time of a poll posted at postedAt
+func ValidateClosesAt(closesAt, postedAt time.Time) error {
+	open := closesAt.Sub(postedAt)
+	if open < MinDuration || open > MaxDuration {
+		return ErrClosesAt
+	}
+	return nil
+}
+
+// ValidateChoices checks a ballot against a poll with the given number of
+// options, choices are option positions starting at 0
+func ValidateChoices(choices []int, options int, multiple bool) error {
+	if len(choices) == 0 {
+		return ErrNoChoice
+	}
+	if len(choices) > 1 && !multiple {
+		return ErrSingleChoice
+	}
+	seen := map[int]bool{}
+	for _, choice := range choices {
+		if choice < 0 || choice >= options || seen[choice] {
+			return ErrUnknown
+		}
+		seen[choice] = true
+	}
+	return nil
+}
+
+// ResultsVisible reports whether counts may be shown to a viewer, they stay
+// hidden until the viewer voted so they can't sway the vote
+func ResultsVisible(voted bool, closesAt, now time.Time) bool {
+	return voted || !now.Before(closesAt)
+}
@@ -0,0 +1,77 @@
+package polls
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		want    error
+	}{
+		{name: "two", options: []string{"yes", "no"}, want: nil},
+		{name: "four", options: []string{"a", "b", "c", "d"}, want: nil},
+		{name: "one", options: []string{"yes"}, want: ErrOptionCount},
+		{name: "five", options: []string{"a", "b", "c", "d", "e"}, want: ErrOptionCount},
+		{name: "blank", options: []string{"yes", "  "}, want: ErrOptionText},
+		{name: "too long", options: []string{"yes", string(make([]byte, MaxOptionLength+1))}, want: ErrOptionText},
+		{name: "same ignoring case", options: []string{"Yes", "yes"}, want: ErrDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateOptions(tt.options); got != tt.want {
+				t.Errorf("ValidateOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateChoices(t *testing.T) {
+	tests := []struct {
+		name     string
+		choices  []int
+		multiple bool
+		want     error
+	}{
+		{name: "single", choices: []int{1}, want: nil},
+		{name: "several on multiple", choices: []int{0, 2}, multiple: true, want: nil},
+		{name: "none", choices: nil, want: ErrNoChoice},
+		{name: "several on single", choices: []int{0, 1}, want: ErrSingleChoice},
+		{name: "out of range", choices: []int{3}, want: ErrUnknown},
+		{name: "negative", choices: []int{-1}, want: ErrUnknown},
+		{name: "repeated", choices: []int{1, 1}, multiple: true, want: ErrUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateChoices(tt.choices, 3, tt.multiple); got != tt.want {
+				t.Errorf("ValidateChoices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultsVisible(t *testing.T) {
+	closesAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		voted bool
+		now   time.Time
+		want  bool
+	}{
+		{name: "open and not voted", now: closesAt.Add(-time.Minute), want: false},
+		{name: "open and voted", voted: true, now: closesAt.Add(-time.Minute), want: true},
+		{name: "closed", now: closesAt, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResultsVisible(tt.voted, closesAt, tt.now); got != tt.want {
+				t.Errorf("ResultsVisible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Weso1ek/chirpy/internal/pgnotify"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Channel is the Postgres NOTIFY channel realtime messages are sent on
+const Channel = "realtime"
+
+const (
+	TypeNotification = "notification"
+	TypeCounter      = "counter"
+	TypeMessage      = "message"
+	TypeReadReceipt  = "read_receipt"
+)
+
+// Message is delivered to every client subscribed to Topic
+type Message struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// NotificationsTopic is private to userID
+func NotificationsTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+// ChirpTopic carries the live counters of a chirp
+func ChirpTopic(chirpID uuid.UUID) string {
+	return "chirp:" + chirpID.String()
+}
+
+// ParseChirpTopic returns the chirp a counter topic is about
+func ParseChirpTopic(topic string) (uuid.UUID, bool) {
+	id, ok := strings.CutPrefix(topic, "chirp:")
+	if !ok {
+		return uuid.Nil, false
+	}
+	chirpID, err := uuid.Parse(id)
+	return chirpID, err == nil
+}
+
+// Client is one connection. Messages are queued on Send; when the queue is
+// full the client is kicked instead of buffering without limit.
+type Client struct {
+	Send   chan Message
+	Kicked chan struct{}
+	once   sync.Once
+}
+
+func (c *Client) kick() {
+	c.once.Do(func() {
+		close(c.Kicked)
+	})
+}
+
+// Hub routes messages to subscribed clients
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]map[*Client]struct{}
+}
+
+// NewHub -
+func NewHub() *Hub {
+	return &Hub{
+		topics: make(map[string]map[*Client]struct{}),
+	}
+}
+
+// NewClient returns a client that can have queue messages pending
+func (h *Hub) NewClient(queue int) *Client {
+	return &Client{
+		Send:   make(chan Message, queue),
+		Kicked: make(chan struct{}),
+	}
+}
+
+// Subscribe -
+func (h *Hub) Subscribe(c *Client, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*Client]struct{})
+	}
+	h.topics[topic][c] = struct{}{}
+}
+
+// Unsubscribe -
+func (h *Hub) Unsubscribe(c *Client, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.topics[topic], c)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// Remove drops c from every topic
+func (h *Hub) Remove(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic, clients := range h.topics {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// Publish hands msg to the subscribers of its topic without blocking
+func (h *Hub) Publish(msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.topics[msg.Topic] {
+		select {
+		case c.Send <- msg:
+		default:
+			c.kick()
+		}
+	}
+}
+
+// Loader fetches a message by the id that went through NOTIFY. Payloads
+// are capped at 8000 bytes, so the message itself is stored.
+type Loader func(ctx context.Context, id uuid.UUID) (Message, error)
+
+// Listen forwards messages raised on any instance to local clients until
+// done is closed
+func (h *Hub) Listen(dbURL string, done <-chan struct{}, load Loader) error {
+	return pgnotify.Listen(dbURL, Channel, done, func(payload string) {
+		id, err := uuid.Parse(payload)
+		if err != nil {
+			log.Printf("Couldn't decode realtime message: %s", err)
+			return
+		}
+		msg, err := load(context.Background(), id)
+		if err != nil {
+			log.Printf("Couldn't load realtime message %s: %s", id, err)
+			return
+		}
+		h.Publish(msg)
+	})
+}
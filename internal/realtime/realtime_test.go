@@ -0,0 +1,53 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHubPublish(t *testing.T) {
+	h := NewHub()
+	userID := uuid.New()
+	topic := NotificationsTopic(userID)
+
+	fast := h.NewClient(10)
+	slow := h.NewClient(1)
+	other := h.NewClient(10)
+	h.Subscribe(fast, topic)
+	h.Subscribe(slow, topic)
+	h.Subscribe(other, NotificationsTopic(uuid.New()))
+
+	h.Publish(Message{Topic: topic, Type: TypeNotification})
+	h.Publish(Message{Topic: topic, Type: TypeNotification})
+
+	if len(fast.Send) != 2 {
+		t.Errorf("fast client has %d messages, want 2", len(fast.Send))
+	}
+	if len(other.Send) != 0 {
+		t.Errorf("client on another topic got %d messages", len(other.Send))
+	}
+	select {
+	case <-slow.Kicked:
+	default:
+		t.Errorf("slow client wasn't kicked when its queue was full")
+	}
+
+	h.Remove(fast)
+	h.Publish(Message{Topic: topic, Type: TypeNotification})
+	if len(fast.Send) != 2 {
+		t.Errorf("removed client still receives messages")
+	}
+}
+
+func TestParseChirpTopic(t *testing.T) {
+	chirpID := uuid.New()
+
+	got, ok := ParseChirpTopic(ChirpTopic(chirpID))
+	if !ok || got != chirpID {
+		t.Errorf("ParseChirpTopic() = %v, %v, want %v", got, ok, chirpID)
+	}
+	if _, ok := ParseChirpTopic(NotificationsTopic(chirpID)); ok {
+		t.Errorf("ParseChirpTopic() accepted a notifications topic")
+	}
+}
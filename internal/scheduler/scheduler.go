@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+// Task is a periodic piece of maintenance work
+type Task struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs tasks on their interval. Every replica runs a Scheduler;
+// a Postgres advisory lock per task elects the one that does the work and
+// the recorded last run stops the others from repeating it right after.
+type Scheduler struct {
+	db    *sql.DB
+	tasks []Task
+	Tick  time.Duration
+}
+
+// New -
+func New(db *sql.DB) *Scheduler {
+	return &Scheduler{
+		db:   db,
+		Tick: 30 * time.Second,
+	}
+}
+
+// Add registers a task, a zero interval disables it
+func (s *Scheduler) Add(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		log.Printf("Scheduled task %s is disabled", name)
+		return
+	}
+	s.tasks = append(s.tasks, Task{
+		Name:     name,
+		Interval: interval,
+		Run:      run,
+	})
+}
+
+// Run checks for due tasks every Tick until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Tick)
+	defer ticker.Stop()
+
+	for {
+		for _, task := range s.tasks {
+			err := s.runIfDue(ctx, task)
+			if err != nil {
+				log.Printf("Scheduled task %s failed: %s", task.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LockKey maps a task name to the advisory lock it is guarded by
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("chirpy-scheduler:" + name))
+	return int64(h.Sum64())
+}
+
+func (s *Scheduler) runIfDue(ctx context.Context, task Task) error {
+	// advisory locks belong to a session, so hold one connection throughout
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	q := database.New(conn)
+
+	key := LockKey(task.Name)
+	locked, err := q.TryAdvisoryLock(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer q.AdvisoryUnlock(context.WithoutCancel(ctx), key)
+
+	last, err := q.GetScheduledTask(ctx, task.Name)
+	if err == nil && time.Since(last.LastRunAt) < task.Interval {
+		return nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	start := time.Now()
+	errRun := task.Run(ctx)
+
+	// a failed run is still recorded so it isn't retried every tick
+	err = q.MarkScheduledTaskRun(ctx, task.Name)
+	if err != nil {
+		return err
+	}
+	if errRun != nil {
+		return errRun
+	}
+
+	log.Printf("Scheduled task %s finished in %s", task.Name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
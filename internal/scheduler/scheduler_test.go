@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockKey(t *testing.T) {
+	if LockKey("prune-refresh-tokens") != LockKey("prune-refresh-tokens") {
+		t.Errorf("LockKey() is not stable")
+	}
+	if LockKey("prune-refresh-tokens") == LockKey("cleanup-orphaned-data") {
+		t.Errorf("LockKey() collides for different tasks")
+	}
+}
+
+func TestAddSkipsDisabledTasks(t *testing.T) {
+	s := New(nil)
+	s.Add("enabled", time.Minute, nil)
+	s.Add("disabled", 0, nil)
+
+	if len(s.tasks) != 1 || s.tasks[0].Name != "enabled" {
+		t.Errorf("Add() registered %+v, want only the enabled task", s.tasks)
+	}
+}
@@ -0,0 +1,232 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Weso1ek/chirpy/internal/pgnotify"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Channel is the Postgres NOTIFY channel chirp events are sent on
+const Channel = "chirp_events"
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpEdited  = "chirp.edited"
+	EventChirpDeleted = "chirp.deleted"
+)
+
+// Chirp is the part of a chirp the stream needs for filtering, the rest
+// of the JSON is passed through untouched
+type Chirp struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	UserId     uuid.UUID `json:"user_id"`
+	Visibility string    `json:"visibility"`
+}
+
+// Notice is what goes through NOTIFY. Payloads are capped at 8000 bytes,
+// so the chirp itself is loaded by each listener.
+type Notice struct {
+	ID      int64     `json:"id"`
+	Type    string    `json:"type"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// Loader builds the event for a notice
+type Loader func(ctx context.Context, notice Notice) (Event, error)
+
+// Event is one notification, IDs come from a database sequence so every
+// instance agrees on them and Last-Event-ID works wherever a client lands
+type Event struct {
+	ID    int64
+	Type  string
+	Chirp json.RawMessage
+
+	parsed Chirp
+}
+
+// NewEvent -
+func NewEvent(id int64, eventType string, chirp json.RawMessage) (Event, error) {
+	event := Event{
+		ID:    id,
+		Type:  eventType,
+		Chirp: chirp,
+	}
+	err := json.Unmarshal(chirp, &event.parsed)
+	if err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// ChirpID returns the id of the chirp the event is about
+func (e Event) ChirpID() uuid.UUID {
+	return e.parsed.ID
+}
+
+// Author returns the user that wrote the chirp
+func (e Event) Author() uuid.UUID {
+	return e.parsed.UserId
+}
+
+// Visibility returns who the author meant the chirp for
+func (e Event) Visibility() string {
+	return e.parsed.Visibility
+}
+
+// Body returns the text of the chirp
+func (e Event) Body() string {
+	return e.parsed.Body
+}
+
+// HasHashtag reports whether the chirp is tagged with tag (without the #)
+func (e Event) HasHashtag(tag string) bool {
+	for _, t := range Hashtags(e.parsed.Body) {
+		if t == strings.ToLower(strings.TrimPrefix(tag, "#")) {
+			return true
+		}
+	}
+	return false
+}
+
+var hashtagRegexp = regexp.MustCompile(`(?:^|\s)#(\w+)`)
+
+// Hashtags returns the lower cased tags in body
+func Hashtags(body string) []string {
+	var tags []string
+	for _, m := range hashtagRegexp.FindAllStringSubmatch(body, -1) {
+		tags = append(tags, strings.ToLower(m[1]))
+	}
+	return tags
+}
+
+// Subscriber receives events on C. C is closed when the subscriber falls
+// too far behind so a slow client can't hold on to memory.
+type Subscriber struct {
+	C      chan Event
+	filter func(Event) bool
+}
+
+// Broker fans events out to subscribers and keeps the last few for resume
+type Broker struct {
+	mu     sync.Mutex
+	buffer []Event
+	size   int
+	// floor is the last event that can't be replayed anymore. IDs have gaps
+	// where a transaction rolled back, so it's tracked rather than guessed
+	// from the first buffered event.
+	floor       int64
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewBroker returns a broker that remembers the last size events
+func NewBroker(size int) *Broker {
+	return &Broker{
+		size:        size,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish stores event in the replay buffer and hands it to subscribers
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// anything before the first event arrived was never seen here
+	if b.floor == 0 && len(b.buffer) == 0 {
+		b.floor = event.ID - 1
+	}
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > b.size {
+		evicted := b.buffer[:len(b.buffer)-b.size]
+		b.floor = evicted[len(evicted)-1].ID
+		b.buffer = b.buffer[len(b.buffer)-b.size:]
+	}
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the buffered events after
+// lastID that pass filter. complete is false when events after lastID
+// have already left the buffer.
+func (b *Broker) Subscribe(lastID int64, filter func(Event) bool) (sub *Subscriber, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscriber{
+		C:      make(chan Event, 64),
+		filter: filter,
+	}
+	b.subscribers[sub] = struct{}{}
+
+	complete = true
+	if lastID > 0 {
+		if lastID < b.floor {
+			complete = false
+		}
+		for _, event := range b.buffer {
+			if event.ID > lastID && (filter == nil || filter(event)) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return sub, missed, complete
+}
+
+// Unsubscribe removes sub, it is safe to call after the broker dropped it
+func (b *Broker) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.C)
+	}
+}
+
+// DecodeNotice parses a NOTIFY payload
+func DecodeNotice(payload string) (Notice, error) {
+	notice := Notice{}
+	err := json.Unmarshal([]byte(payload), &notice)
+	if err != nil {
+		return Notice{}, err
+	}
+	return notice, nil
+}
+
+// Listen forwards notifications from Postgres to the broker until done
+// is closed, so events raised on any instance reach clients on all of them.
+// NOTIFY is delivered in commit order and event IDs are handed out in that
+// order too, so the buffer stays sorted.
+func (b *Broker) Listen(dbURL string, done <-chan struct{}, load Loader) error {
+	return pgnotify.Listen(dbURL, Channel, done, func(payload string) {
+		notice, err := DecodeNotice(payload)
+		if err != nil {
+			log.Printf("Couldn't decode stream event: %s", err)
+			return
+		}
+		event, err := load(context.Background(), notice)
+		if err != nil {
+			log.Printf("Couldn't load stream event %d: %s", notice.ID, err)
+			return
+		}
+		b.Publish(event)
+	})
+}
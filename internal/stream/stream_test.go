@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func event(t *testing.T, id int64, author uuid.UUID, body string) Event {
+	t.Helper()
+	chirp := fmt.Sprintf(`{"id":"%s","body":%q,"user_id":"%s"}`, uuid.New(), body, author)
+	e, err := NewEvent(id, EventChirpCreated, json.RawMessage(chirp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestBrokerResume(t *testing.T) {
+	author := uuid.New()
+	b := NewBroker(3)
+	for i := int64(1); i <= 5; i++ {
+		b.Publish(event(t, i, author, "hello"))
+	}
+
+	_, missed, complete := b.Subscribe(3, nil)
+	if !complete || len(missed) != 2 || missed[0].ID != 4 {
+		t.Errorf("Subscribe(3) = %d events, complete %v, want events 4 and 5", len(missed), complete)
+	}
+
+	_, missed, complete = b.Subscribe(1, nil)
+	if complete || len(missed) != 3 {
+		t.Errorf("Subscribe(1) = %d events, complete %v, want 3 events and incomplete", len(missed), complete)
+	}
+}
+
+func TestBrokerResumeAcrossGaps(t *testing.T) {
+	author := uuid.New()
+	b := NewBroker(2)
+	// 2 was rolled back and never sent
+	for _, id := range []int64{1, 3, 4} {
+		b.Publish(event(t, id, author, "hello"))
+	}
+
+	_, missed, complete := b.Subscribe(1, nil)
+	if !complete || len(missed) != 2 || missed[0].ID != 3 {
+		t.Errorf("Subscribe(1) = %d events, complete %v, want events 3 and 4", len(missed), complete)
+	}
+
+	_, _, complete = b.Subscribe(0, nil)
+	if !complete {
+		t.Errorf("Subscribe(0) complete = false, want true for a new client")
+	}
+}
+
+func TestBrokerFilterAndBackpressure(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	b := NewBroker(10)
+
+	sub, _, _ := b.Subscribe(0, func(e Event) bool { return e.HasHashtag("Go") })
+	b.Publish(event(t, 1, alice, "nothing to see"))
+	b.Publish(event(t, 2, bob, "learning #go today"))
+
+	got := <-sub.C
+	if got.ID != 2 || got.Author() != bob {
+		t.Errorf("subscriber got event %d, want 2", got.ID)
+	}
+
+	for i := int64(3); i < 100; i++ {
+		b.Publish(event(t, i, alice, "#go"))
+	}
+	drained := 0
+	for range sub.C {
+		drained++
+	}
+	if drained != cap(sub.C) {
+		t.Errorf("slow subscriber received %d events, want it dropped after %d", drained, cap(sub.C))
+	}
+	b.Unsubscribe(sub)
+}
+
+func TestHashtags(t *testing.T) {
+	tags := Hashtags("#Go and #sql, not a#tag? #go")
+	want := []string{"go", "sql", "go"}
+	if fmt.Sprint(tags) != fmt.Sprint(want) {
+		t.Errorf("Hashtags() = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,26 @@
+package visibility
+
+const (
+	Public = "public"
+	// Unlisted chirps can be read by anyone but only show up on the
+	// author's own listing, never in public ones
+	Unlisted  = "unlisted"
+	Followers = "followers"
+	// Mentioned chirps are only for the users they mention
+	Mentioned = "mentioned"
+)
+
+// Valid reports whether v is a known visibility
+func Valid(v string) bool {
+	switch v {
+	case Public, Unlisted, Followers, Mentioned:
+		return true
+	}
+	return false
+}
+
+// Listed reports whether a chirp belongs in listings other than its
+// author's
+func Listed(v string) bool {
+	return v != Unlisted
+}
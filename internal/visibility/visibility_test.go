@@ -0,0 +1,23 @@
+package visibility
+
+import "testing"
+
+func TestListed(t *testing.T) {
+	tests := []struct {
+		visibility string
+		want       bool
+	}{
+		{visibility: Public, want: true},
+		{visibility: Unlisted, want: false},
+		{visibility: Followers, want: true},
+		{visibility: Mentioned, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.visibility, func(t *testing.T) {
+			if got := Listed(tt.visibility); got != tt.want {
+				t.Errorf("Listed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirpCreated        = "chirp.created"
+	EventChirpDeleted        = "chirp.deleted"
+	EventUserFollowed        = "user.followed"
+	EventSubscriptionChanged = "subscription.changed"
+)
+
+// Events lists every event integrators can subscribe to
+var Events = []string{
+	EventChirpCreated,
+	EventChirpDeleted,
+	EventUserFollowed,
+	EventSubscriptionChanged,
+}
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Envelope is the body POSTed to subscribers
+type Envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type publishOptions struct {
+	audience   uuid.NullUUID
+	recipients []uuid.UUID
+	subject    uuid.NullUUID
+}
+
+// Option changes how an event is published
+type Option func(*publishOptions)
+
+// Audience limits delivery to webhooks owned by userID or by its followers,
+// for events about a private account
+func Audience(userID uuid.UUID) Option {
+	return func(o *publishOptions) {
+		o.audience = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+}
+
+// Recipients limits delivery to webhooks owned by userIDs, for events that
+// concern nobody else
+func Recipients(userIDs ...uuid.UUID) Option {
+	return func(o *publishOptions) {
+		o.recipients = userIDs
+	}
+}
+
+// Subject is the user an event is about, webhooks of users blocking or
+// blocked by them don't get it
+func Subject(userID uuid.UUID) Option {
+	return func(o *publishOptions) {
+		o.subject = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+}
+
+// Publish writes an event to the outbox. Call it with a Queries bound to
+// the same transaction as the change that triggered it so neither can be
+// lost without the other.
+func Publish(ctx context.Context, q *database.Queries, eventType string, data any, opts ...Option) error {
+	options := publishOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+
+	_, err = q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType:    eventType,
+		Payload:      dat,
+		AudienceID:   options.audience,
+		RecipientIds: options.recipients,
+		SubjectID:    options.subject,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %w", eventType, err)
+	}
+	return nil
+}
+
+// Backoff returns how long to wait before retrying after attempt failures
+func Backoff(attempt int) time.Duration {
+	const (
+		base    = 30 * time.Second
+		maxWait = 6 * time.Hour
+	)
+	if attempt < 1 {
+		return base
+	}
+	wait := base
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= maxWait {
+			return maxWait
+		}
+	}
+	return wait
+}
+
+// Worker fans outbox events out to subscribers and delivers them
+type Worker struct {
+	db          *sql.DB
+	queries     *database.Queries
+	client      *http.Client
+	Interval    time.Duration
+	BatchSize   int32
+	MaxAttempts int32
+}
+
+// NewWorker returns a worker that only connects to public addresses,
+// allowPrivate lifts that for local development
+func NewWorker(db *sql.DB, queries *database.Queries, allowPrivate bool) *Worker {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = checkDialAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Worker{
+		db:      db,
+		queries: queries,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			// a redirect could lead anywhere, subscribers get the one url
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Interval:    5 * time.Second,
+		BatchSize:   50,
+		MaxAttempts: 10,
+	}
+}
+
+// Run polls until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := w.dispatch(ctx)
+		if err != nil {
+			log.Printf("Webhook dispatch failed: %s", err)
+		}
+		err = w.deliver(ctx)
+		if err != nil {
+			log.Printf("Webhook delivery failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch turns pending outbox events into one delivery per subscriber
+func (w *Worker) dispatch(ctx context.Context) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := w.queries.WithTx(tx)
+
+	events, err := q.ClaimOutboxEvents(ctx, w.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		err = q.CreateWebhookDeliveries(ctx, database.CreateWebhookDeliveriesParams{
+			EventID:      event.ID,
+			EventType:    event.EventType,
+			AudienceID:   event.AudienceID,
+			RecipientIds: event.RecipientIds,
+			SubjectID:    event.SubjectID,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.MarkOutboxEventDispatched(ctx, event.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// deliver sends due deliveries, claiming them with a lease so replicas
+// don't send the same one twice
+func (w *Worker) deliver(ctx context.Context) error {
+	deliveries, err := w.queries.ClaimWebhookDeliveries(ctx, w.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, errSend := w.send(ctx, delivery)
+		code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+
+		if errSend == nil {
+			err = w.queries.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+				ID:             delivery.ID,
+				LastStatusCode: code,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		status := StatusPending
+		if delivery.Attempts+1 >= w.MaxAttempts {
+			status = StatusDead
+		}
+		err = w.queries.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			ID:             delivery.ID,
+			Status:         status,
+			NextAttemptAt:  time.Now().UTC().Add(Backoff(int(delivery.Attempts) + 1)),
+			LastStatusCode: code,
+			LastError:      sql.NullString{String: errSend.Error(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Worker) send(ctx context.Context, delivery database.WebhookDelivery) (int, error) {
+	subscription, err := w.queries.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't load subscription: %w", err)
+	}
+	event, err := w.queries.GetOutboxEvent(ctx, delivery.EventID)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't load event: %w", err)
+	}
+
+	body, err := json.Marshal(Envelope{
+		ID:        event.ID,
+		Type:      event.EventType,
+		CreatedAt: event.CreatedAt.Time,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chirpy-Event", event.EventType)
+	req.Header.Set("X-Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set("X-Chirpy-Timestamp", timestamp)
+	req.Header.Set("X-Chirpy-Signature", "v1="+auth.SignWebhook(subscription.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"github.com/Weso1ek/chirpy/internal/jobs"
+	"github.com/Weso1ek/chirpy/internal/mail"
+)
+
+const (
+	jobSendMail     = "mail.send"
+	jobProcessMedia = "media.process"
+	jobPublishChirp = "chirp.publish"
+	jobClosePoll    = "poll.close"
+)
+
+// registerJobs wires every background job handler into queue
+func (cfg *apiConfig) registerJobs(queue *jobs.Queue) {
+	jobs.Handle(queue, jobSendMail, 4, func(ctx context.Context, msg mail.Message) error {
+		return cfg.mailer.Send(ctx, msg)
+	})
+	jobs.Handle(queue, jobProcessMedia, 2, cfg.processMedia)
+	jobs.Handle(queue, jobPublishChirp, 4, cfg.publishScheduledChirp)
+	jobs.Handle(queue, jobClosePoll, 2, cfg.closePoll)
+}
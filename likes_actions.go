@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/notifications"
+	"github.com/Weso1ek/chirpy/internal/realtime"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// publishLikeCount pushes the current like count to the chirp's counter topic
+func publishLikeCount(ctx context.Context, q *database.Queries, chirpID uuid.UUID) error {
+	count, err := q.CountChirpLikes(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	return publishRealtime(ctx, q, realtime.ChirpTopic(chirpID), realtime.TypeCounter, struct {
+		Likes int64 `json:"likes"`
+	}{
+		Likes: count,
+	})
+}
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirpForViewer(r.Context(), database.GetChirpForViewerParams{
+		ID:       chirpUUID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	liked, err := q.LikeChirp(r.Context(), database.LikeChirpParams{
+		ChirpID: chirpUUID,
+		UserID:  userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+
+	// liking twice is a no-op and doesn't notify again
+	if liked > 0 {
+		err = notify(r.Context(), q, newNotification{
+			UserID:  chirp.UserID,
+			Type:    notifications.TypeLike,
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+			ChirpID: uuid.NullUUID{UUID: chirpUUID, Valid: true},
+			Subject: chirpUUID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+			return
+		}
+
+		err = publishLikeCount(r.Context(), q, chirpUUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	unliked, err := cfg.dbQueries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		ChirpID: chirpUUID,
+		UserID:  userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+
+	if unliked > 0 {
+		err = publishLikeCount(r.Context(), cfg.dbQueries, chirpUUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/jobs"
+	"github.com/Weso1ek/chirpy/internal/mail"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	magicLinkTTL         = 15 * time.Minute
+	magicLinkNonceCookie = "chirpy_magic_nonce"
+)
+
+func (cfg *apiConfig) handlerLoginMagic(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// the nonce ties the link to this browser, only its hash is stored
+	nonce, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create nonce", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkNonceCookie,
+		Value:    nonce,
+		Path:     "/api/login/magic",
+		MaxAge:   int(magicLinkTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.platform != "dev",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// unknown addresses get the same answer so accounts can't be enumerated
+	user, err := cfg.dbQueries.GetUserByLogin(r.Context(), sql.NullString{String: params.Email, Valid: true})
+	if err != nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create magic link", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save magic link", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	_, err = q.CreateMagicLink(r.Context(), database.CreateMagicLinkParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		NonceHash: auth.HashToken(nonce),
+		ExpiresAt: time.Now().UTC().Add(magicLinkTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save magic link", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/api/login/magic/verify?token=%s", cfg.baseURL, url.QueryEscape(token))
+
+	// the mail is sent by the job queue once the link is committed
+	_, err = jobs.Enqueue(r.Context(), q, jobSendMail, mail.Message{
+		To:      user.Email.String,
+		Subject: "Your Chirpy sign-in link",
+		Body:    fmt.Sprintf("Open this link in the same browser to sign in to Chirpy:\n\n%s\n\nThe link expires in %d minutes and can only be used once.\n", link, int(magicLinkTTL.Minutes())),
+	}, jobs.MaxAttempts(5))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send magic link", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save magic link", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlerLoginMagicVerify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing token", nil)
+		return
+	}
+
+	nonce, err := r.Cookie(magicLinkNonceCookie)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Magic link was requested from another browser", err)
+		return
+	}
+
+	link, err := cfg.dbQueries.ConsumeMagicLink(r.Context(), auth.HashToken(token))
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired magic link", err)
+		return
+	}
+
+	if !auth.CompareTokens(link.NonceHash, auth.HashToken(nonce.Value)) {
+		respondWithError(w, http.StatusUnauthorized, "Magic link was requested from another browser", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(r.Context(), link.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User not found", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   magicLinkNonceCookie,
+		Path:   "/api/login/magic",
+		MaxAge: -1,
+	})
+
+	cfg.respondWithSession(w, r, user)
+}
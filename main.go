@@ -1,23 +1,48 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/Weso1ek/chirpy/internal/auth"
 	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/entitlements"
+	"github.com/Weso1ek/chirpy/internal/jobs"
+	"github.com/Weso1ek/chirpy/internal/mail"
+	"github.com/Weso1ek/chirpy/internal/media"
+	"github.com/Weso1ek/chirpy/internal/realtime"
+	"github.com/Weso1ek/chirpy/internal/scheduler"
+	"github.com/Weso1ek/chirpy/internal/stream"
+	"github.com/Weso1ek/chirpy/internal/webhooks"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      *database.Queries
 	platform       string
 	secret         string
-	polkaKey       string
+	polkaKeys      []string
+	polkaSecrets   []string
+	polkaReplays   *auth.ReplayCache
+	adminKey       string
+	baseURL        string
+	mailer         mail.Sender
+	entitlements   *entitlements.Engine
+	stream         *stream.Broker
+	realtime       *realtime.Hub
+	blobs          media.BlobStore
+	// uploads keeps raw files until they're processed, they still carry
+	// their metadata so it is never served
+	uploads media.BlobStore
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -67,36 +92,190 @@ func main() {
 
 	var cfg apiConfig
 
+	cfg.db = db
 	cfg.dbQueries = database.New(db)
+	cfg.stream = stream.NewBroker(1000)
+	cfg.realtime = realtime.NewHub()
 	cfg.platform = os.Getenv("PLATFORM")
 	cfg.secret = os.Getenv("JWT_SECRET")
-	cfg.polkaKey = os.Getenv("POLKA_KEY")
+	// comma separated so keys and secrets can be rotated without downtime
+	cfg.polkaKeys = strings.Split(os.Getenv("POLKA_KEY"), ",")
+	if secrets := os.Getenv("POLKA_WEBHOOK_SECRETS"); secrets != "" {
+		cfg.polkaSecrets = strings.Split(secrets, ",")
+	} else {
+		log.Printf("WARNING: POLKA_WEBHOOK_SECRETS is not set, every Polka webhook will be refused")
+	}
+	cfg.polkaReplays = auth.NewReplayCache(polkaSignatureTolerance)
+	cfg.adminKey = os.Getenv("ADMIN_KEY")
+
+	plans, err := entitlements.Load(os.Getenv("ENTITLEMENTS_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.entitlements, err = entitlements.New(plans, planFree)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg.baseURL = os.Getenv("BASE_URL")
+	if cfg.baseURL == "" {
+		cfg.baseURL = "http://localhost:" + port
+	}
+
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		cfg.mailer = &mail.SMTPSender{Addr: smtpAddr, From: os.Getenv("MAIL_FROM")}
+	} else {
+		cfg.mailer = mail.LogSender{}
+	}
+
+	// S3_ENDPOINT can point at a local stand-in such as MinIO
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		cfg.blobs = &media.S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    bucket,
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		}
+		uploadBucket := os.Getenv("S3_UPLOAD_BUCKET")
+		if uploadBucket == "" {
+			log.Printf("WARNING: S3_UPLOAD_BUCKET is not set, raw uploads go to %s and must not be publicly readable", bucket)
+			uploadBucket = bucket
+		}
+		cfg.uploads = &media.S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    uploadBucket,
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+	} else {
+		cfg.blobs = &media.FSStore{
+			Dir:     filepath.Join(filepathRoot, "assets", "media"),
+			BaseURL: cfg.baseURL + "/app/assets/media",
+		}
+		// outside filepathRoot, everything below it is served under /app/
+		uploadDir := os.Getenv("MEDIA_UPLOAD_DIR")
+		if uploadDir == "" {
+			uploadDir = filepath.Join(os.TempDir(), "chirpy-uploads")
+		}
+		cfg.uploads = &media.FSStore{
+			Dir: uploadDir,
+		}
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
 	mux.Handle("GET /api/healthz", http.HandlerFunc(Health))
 	mux.Handle("POST /api/login", http.HandlerFunc(cfg.handlerLogin))
+	mux.Handle("POST /api/login/magic", http.HandlerFunc(cfg.handlerLoginMagic))
+	mux.Handle("GET /api/login/magic/verify", http.HandlerFunc(cfg.handlerLoginMagicVerify))
+	mux.Handle("POST /api/media", http.HandlerFunc(cfg.handlerMediaUpload))
+	mux.Handle("GET /api/media/{mediaID}", http.HandlerFunc(cfg.handlerGetMedia))
+	mux.Handle("PUT /api/media/{mediaID}", http.HandlerFunc(cfg.handlerMediaUpdate))
 	mux.Handle("POST /api/users", http.HandlerFunc(cfg.handlerUsersCreate))
 	mux.Handle("PUT /api/users", http.HandlerFunc(cfg.handlerUsersUpdate))
+	mux.Handle("DELETE /api/users", http.HandlerFunc(cfg.handlerUsersDelete))
+	mux.Handle("POST /api/users/restore", http.HandlerFunc(cfg.handlerUsersRestore))
+	mux.Handle("GET /api/users/search", http.HandlerFunc(cfg.handlerUsersSearch))
+	mux.Handle("GET /api/users/{handle}", http.HandlerFunc(cfg.handlerGetProfile))
 	mux.Handle("POST /api/chirps", http.HandlerFunc(cfg.handlerChirpsCreate))
 	mux.Handle("GET /api/chirps", http.HandlerFunc(cfg.handlerChirps))
+	mux.Handle("GET /api/chirps/deleted", http.HandlerFunc(cfg.handlerDeletedChirps))
 	mux.Handle("GET /api/chirps/{chirpID}", http.HandlerFunc(cfg.handlerGetChirp))
+	mux.Handle("PUT /api/chirps/{chirpID}", http.HandlerFunc(cfg.handlerChirpsUpdate))
 	mux.Handle("DELETE /api/chirps/{chirpID}", http.HandlerFunc(cfg.handlerDeleteChirp))
+	mux.Handle("POST /api/chirps/{chirpID}/restore", http.HandlerFunc(cfg.handlerChirpRestore))
+	mux.Handle("POST /api/chirps/{chirpID}/likes", http.HandlerFunc(cfg.handlerLikeChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}/likes", http.HandlerFunc(cfg.handlerUnlikeChirp))
+	mux.Handle("PUT /api/chirps/{chirpID}/bookmark", http.HandlerFunc(cfg.handlerBookmarkChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}/bookmark", http.HandlerFunc(cfg.handlerUnbookmarkChirp))
+	mux.Handle("POST /api/chirps/{chirpID}/poll/votes", http.HandlerFunc(cfg.handlerPollVote))
+	mux.Handle("PUT /api/chirps/{chirpID}/pin", http.HandlerFunc(cfg.handlerPinChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}/pin", http.HandlerFunc(cfg.handlerUnpinChirp))
+	mux.Handle("GET /api/scheduled_chirps", http.HandlerFunc(cfg.handlerScheduledChirps))
+	mux.Handle("PUT /api/scheduled_chirps/{scheduledChirpID}", http.HandlerFunc(cfg.handlerScheduledChirpUpdate))
+	mux.Handle("DELETE /api/scheduled_chirps/{scheduledChirpID}", http.HandlerFunc(cfg.handlerScheduledChirpDelete))
+	mux.Handle("GET /api/bookmarks", http.HandlerFunc(cfg.handlerBookmarks))
+	mux.Handle("GET /api/bookmark_folders", http.HandlerFunc(cfg.handlerBookmarkFolders))
+	mux.Handle("POST /api/bookmark_folders", http.HandlerFunc(cfg.handlerBookmarkFoldersCreate))
+	mux.Handle("PUT /api/bookmark_folders/{folderID}", http.HandlerFunc(cfg.handlerBookmarkFolderUpdate))
+	mux.Handle("DELETE /api/bookmark_folders/{folderID}", http.HandlerFunc(cfg.handlerBookmarkFolderDelete))
+	mux.Handle("GET /api/stream", http.HandlerFunc(cfg.handlerStream))
+	mux.Handle("GET /api/ws", http.HandlerFunc(cfg.handlerWebsocket))
+	mux.Handle("POST /api/users/{userID}/follow", http.HandlerFunc(cfg.handlerFollow))
+	mux.Handle("DELETE /api/users/{userID}/follow", http.HandlerFunc(cfg.handlerUnfollow))
+	mux.Handle("PUT /api/users/privacy", http.HandlerFunc(cfg.handlerUsersPrivacy))
+	mux.Handle("GET /api/follow_requests", http.HandlerFunc(cfg.handlerFollowRequests))
+	mux.Handle("POST /api/follow_requests/{userID}/accept", http.HandlerFunc(cfg.handlerFollowRequestAccept))
+	mux.Handle("POST /api/follow_requests/{userID}/reject", http.HandlerFunc(cfg.handlerFollowRequestReject))
+	mux.Handle("POST /api/users/{userID}/block", http.HandlerFunc(cfg.handlerBlock))
+	mux.Handle("DELETE /api/users/{userID}/block", http.HandlerFunc(cfg.handlerUnblock))
+	mux.Handle("POST /api/users/{userID}/mute", http.HandlerFunc(cfg.handlerMute))
+	mux.Handle("DELETE /api/users/{userID}/mute", http.HandlerFunc(cfg.handlerUnmute))
+	mux.Handle("GET /api/notifications", http.HandlerFunc(cfg.handlerNotifications))
+	mux.Handle("POST /api/notifications/read", http.HandlerFunc(cfg.handlerNotificationsReadAll))
+	mux.Handle("POST /api/notifications/{notificationID}/read", http.HandlerFunc(cfg.handlerNotificationRead))
+	mux.Handle("GET /api/notifications/preferences", http.HandlerFunc(cfg.handlerNotificationPreferences))
+	mux.Handle("PUT /api/notifications/preferences", http.HandlerFunc(cfg.handlerNotificationPreferencesUpdate))
+	mux.Handle("POST /api/conversations", http.HandlerFunc(cfg.handlerConversationsCreate))
+	mux.Handle("GET /api/conversations", http.HandlerFunc(cfg.handlerConversations))
+	mux.Handle("GET /api/conversations/{conversationID}/messages", http.HandlerFunc(cfg.handlerConversationMessages))
+	mux.Handle("POST /api/conversations/{conversationID}/messages", http.HandlerFunc(cfg.handlerMessagesCreate))
+	mux.Handle("PUT /api/conversations/{conversationID}/messages/{messageID}", http.HandlerFunc(cfg.handlerMessageUpdate))
+	mux.Handle("DELETE /api/conversations/{conversationID}/messages/{messageID}", http.HandlerFunc(cfg.handlerMessageDelete))
+	mux.Handle("POST /api/conversations/{conversationID}/read", http.HandlerFunc(cfg.handlerConversationRead))
+	mux.Handle("GET /api/muted_words", http.HandlerFunc(cfg.handlerMutedWords))
+	mux.Handle("POST /api/muted_words", http.HandlerFunc(cfg.handlerMutedWordsCreate))
+	mux.Handle("DELETE /api/muted_words/{mutedWordID}", http.HandlerFunc(cfg.handlerMutedWordDelete))
+	mux.Handle("GET /api/subscriptions", http.HandlerFunc(cfg.handlerSubscriptions))
 	mux.Handle("POST /api/refresh", http.HandlerFunc(cfg.handlerRefresh))
 	mux.Handle("POST /api/revoke", http.HandlerFunc(cfg.handlerRevoke))
 
 	mux.Handle("POST /api/polka/webhooks", http.HandlerFunc(cfg.handlerUserUpgrade))
 
+	mux.Handle("POST /api/webhooks", http.HandlerFunc(cfg.handlerWebhookSubscriptionsCreate))
+	mux.Handle("GET /api/webhooks", http.HandlerFunc(cfg.handlerWebhookSubscriptions))
+	mux.Handle("DELETE /api/webhooks/{webhookID}", http.HandlerFunc(cfg.handlerWebhookSubscriptionDelete))
+	mux.Handle("GET /api/webhooks/{webhookID}/deliveries", http.HandlerFunc(cfg.handlerWebhookDeliveries))
+
 	mux.Handle("GET /admin/metrics", http.HandlerFunc(cfg.Hits))
 	mux.Handle("POST /admin/reset", http.HandlerFunc(cfg.Reset))
+	mux.Handle("GET /admin/webhooks", http.HandlerFunc(cfg.handlerWebhookEvents))
+	mux.Handle("POST /admin/webhooks/{eventID}/replay", http.HandlerFunc(cfg.handlerWebhookEventReplay))
 
 	//mux.Handle("POST /api/validate_chirp", http.HandlerFunc(ValidateChirp))
 
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: cfg.middlewareLiveUser(mux),
 	}
 
+	queue := jobs.New(cfg.dbQueries)
+	cfg.registerJobs(queue)
+	go queue.Run(context.Background())
+
+	go webhooks.NewWorker(db, cfg.dbQueries, cfg.platform == "dev").Run(context.Background())
+
+	go func() {
+		err := cfg.stream.Listen(dbURL, nil, cfg.loadStreamEvent)
+		if err != nil {
+			log.Printf("Stream listener stopped: %s", err)
+		}
+	}()
+
+	go func() {
+		err := cfg.realtime.Listen(dbURL, nil, cfg.loadRealtimeMessage)
+		if err != nil {
+			log.Printf("Realtime listener stopped: %s", err)
+		}
+	}()
+
+	tasks := scheduler.New(db)
+	cfg.registerTasks(tasks)
+	go tasks.Run(context.Background())
+
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(srv.ListenAndServe())
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/scheduler"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// revoked and expired rows are kept for a while to help debugging
+	staleRefreshTokenRetention = 7 * 24 * time.Hour
+	finishedJobRetention       = 7 * 24 * time.Hour
+	outboxEventRetention       = 30 * 24 * time.Hour
+	// every listener loads a message right after it's published
+	realtimeMessageRetention = time.Hour
+)
+
+// taskInterval reads TASK_INTERVAL_<NAME> (e.g. "6h", "0" to disable)
+func taskInterval(name string, fallback time.Duration) time.Duration {
+	key := "TASK_INTERVAL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return interval
+}
+
+// registerTasks adds the periodic maintenance tasks to s
+func (cfg *apiConfig) registerTasks(s *scheduler.Scheduler) {
+	s.Add("prune-refresh-tokens", taskInterval("prune-refresh-tokens", time.Hour), cfg.pruneRefreshTokens)
+	s.Add("cleanup-orphaned-data", taskInterval("cleanup-orphaned-data", 6*time.Hour), cfg.cleanupOrphanedData)
+	s.Add("reconcile-counters", taskInterval("reconcile-counters", 24*time.Hour), cfg.reconcileCounters)
+	s.Add("purge-deleted", taskInterval("purge-deleted", time.Hour), cfg.purgeDeleted)
+}
+
+func (cfg *apiConfig) pruneRefreshTokens(ctx context.Context) error {
+	deleted, err := cfg.dbQueries.DeleteStaleRefreshTokens(ctx, time.Now().UTC().Add(-staleRefreshTokenRetention))
+	if err != nil {
+		return fmt.Errorf("couldn't prune refresh tokens: %w", err)
+	}
+	log.Printf("Pruned %d refresh tokens", deleted)
+	return nil
+}
+
+func (cfg *apiConfig) cleanupOrphanedData(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	links, err := cfg.dbQueries.DeleteStaleMagicLinks(ctx, now)
+	if err != nil {
+		return fmt.Errorf("couldn't delete magic links: %w", err)
+	}
+
+	finished, err := cfg.dbQueries.DeleteFinishedJobs(ctx, now.Add(-finishedJobRetention))
+	if err != nil {
+		return fmt.Errorf("couldn't delete finished jobs: %w", err)
+	}
+
+	events, err := cfg.dbQueries.DeleteDispatchedOutboxEvents(ctx, now.Add(-outboxEventRetention))
+	if err != nil {
+		return fmt.Errorf("couldn't delete outbox events: %w", err)
+	}
+
+	mutedWords, err := cfg.dbQueries.DeleteExpiredMutedWords(ctx, now)
+	if err != nil {
+		return fmt.Errorf("couldn't delete expired muted words: %w", err)
+	}
+
+	redirects, err := cfg.dbQueries.DeleteExpiredHandleRedirects(ctx, now)
+	if err != nil {
+		return fmt.Errorf("couldn't delete expired handle redirects: %w", err)
+	}
+
+	signatures, err := cfg.dbQueries.DeleteExpiredWebhookSignatures(ctx, now)
+	if err != nil {
+		return fmt.Errorf("couldn't delete expired webhook signatures: %w", err)
+	}
+
+	realtimeMessages, err := cfg.dbQueries.DeleteOldRealtimeMessages(ctx, now.Add(-realtimeMessageRetention))
+	if err != nil {
+		return fmt.Errorf("couldn't delete realtime messages: %w", err)
+	}
+
+	log.Printf("Deleted %d magic links, %d finished jobs, %d outbox events, %d muted words, %d handle redirects, %d webhook signatures and %d realtime messages", links, finished, events, mutedWords, redirects, signatures, realtimeMessages)
+	return nil
+}
+
+// reconcileCounters fixes follower counts that drifted from the follows
+// table, the triggers keeping them should make this a no-op
+func (cfg *apiConfig) reconcileCounters(ctx context.Context) error {
+	fixed, err := cfg.dbQueries.ReconcileFollowerCounts(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't reconcile follower counts: %w", err)
+	}
+	log.Printf("Reconciled %d follower counts", fixed)
+	return nil
+}
+
+// purgeDeleted hard-deletes chirps and accounts whose restore window has
+// passed, the cascades take everything that hangs off them along
+func (cfg *apiConfig) purgeDeleted(ctx context.Context) error {
+	cutoff := restoreCutoff()
+
+	// the rows go with the chirps and accounts but the files have to be
+	// removed here, nothing past the cutoff can be restored in between
+	keys, err := cfg.dbQueries.ListPurgeableMediaKeys(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("couldn't list media of deleted chirps and users: %w", err)
+	}
+
+	// chirp_media only cascades the link, not the media it points at
+	media, err := cfg.dbQueries.PurgeDeletedChirpMedia(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("couldn't purge media of deleted chirps: %w", err)
+	}
+	chirps, err := cfg.dbQueries.PurgeDeletedChirps(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("couldn't purge deleted chirps: %w", err)
+	}
+	users, err := cfg.dbQueries.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("couldn't purge deleted users: %w", err)
+	}
+	for _, key := range keys {
+		err = cfg.mediaStore(key).Delete(ctx, key)
+		if err != nil {
+			log.Printf("Couldn't delete media %s: %s", key, err)
+		}
+	}
+
+	log.Printf("Purged %d chirps, %d chirp media and %d users", chirps, media, users)
+	return nil
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/jobs"
+	"github.com/Weso1ek/chirpy/internal/media"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxMediaSize     = 10 << 20
+	maxAltTextLength = 1000
+	// raw uploads are kept under this prefix in cfg.uploads
+	uploadKeyPrefix = "uploads/"
+)
+
+// media moves from uploading to processing, then ends up ready or failed
+const (
+	mediaProcessing = "processing"
+	mediaReady      = "ready"
+)
+
+// mediaStore returns the store a key of the media table lives in, only
+// processed files are in the public one
+func (cfg *apiConfig) mediaStore(key string) media.BlobStore {
+	if strings.HasPrefix(key, uploadKeyPrefix) {
+		return cfg.uploads
+	}
+	return cfg.blobs
+}
+
+type MediaVariant struct {
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+type Media struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	// URL and the variants are only set once processing is done, the raw
+	// upload still carries its metadata
+	URL         string                  `json:"url,omitempty"`
+	ContentType string                  `json:"content_type"`
+	Size        int64                   `json:"size"`
+	Width       int32                   `json:"width,omitempty"`
+	Height      int32                   `json:"height,omitempty"`
+	Blurhash    string                  `json:"blurhash,omitempty"`
+	Variants    map[string]MediaVariant `json:"variants,omitempty"`
+	AltText     string                  `json:"alt_text"`
+}
+
+func (cfg *apiConfig) mediaFromDB(m database.Medium, variants []database.MediaVariant) Media {
+	resp := Media{
+		ID:          m.ID,
+		Status:      m.Status,
+		ContentType: m.ContentType,
+		Size:        m.SizeBytes,
+		AltText:     m.AltText,
+	}
+	if m.Status != mediaReady {
+		return resp
+	}
+
+	resp.URL = cfg.blobs.URL(m.StorageKey)
+	resp.Width = m.Width
+	resp.Height = m.Height
+	resp.Blurhash = m.Blurhash
+	for _, v := range variants {
+		if resp.Variants == nil {
+			resp.Variants = map[string]MediaVariant{}
+		}
+		resp.Variants[v.Name] = MediaVariant{
+			URL:    cfg.blobs.URL(v.StorageKey),
+			Width:  v.Width,
+			Height: v.Height,
+		}
+	}
+	return resp
+}
+
+// loadMedia builds the responses for list, fetching all their variants
+// at once
+func (cfg *apiConfig) loadMedia(ctx context.Context, q *database.Queries, list []database.Medium) ([]Media, error) {
+	ids := make([]uuid.UUID, 0, len(list))
+	for _, m := range list {
+		ids = append(ids, m.ID)
+	}
+	variants, err := q.ListMediaVariants(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byMedia := map[uuid.UUID][]database.MediaVariant{}
+	for _, v := range variants {
+		byMedia[v.MediaID] = append(byMedia[v.MediaID], v)
+	}
+
+	resp := make([]Media, 0, len(list))
+	for _, m := range list {
+		resp = append(resp, cfg.mediaFromDB(m, byMedia[m.ID]))
+	}
+	return resp, nil
+}
+
+// chirpMedia loads the attachments of chirpIDs in the order they were
+// attached
+func (cfg *apiConfig) chirpMedia(ctx context.Context, q *database.Queries, chirpIDs ...uuid.UUID) (map[uuid.UUID][]Media, error) {
+	resp := map[uuid.UUID][]Media{}
+	if len(chirpIDs) == 0 {
+		return resp, nil
+	}
+
+	rows, err := q.ListMediaForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]database.Medium, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, database.Medium{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			UserID:      row.UserID,
+			StorageKey:  row.StorageKey,
+			ContentType: row.ContentType,
+			SizeBytes:   row.SizeBytes,
+			AltText:     row.AltText,
+			Status:      row.Status,
+			Width:       row.Width,
+			Height:      row.Height,
+			Blurhash:    row.Blurhash,
+			Failure:     row.Failure,
+		})
+	}
+	attachments, err := cfg.loadMedia(ctx, q, list)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		resp[row.ChirpID] = append(resp[row.ChirpID], attachments[i])
+	}
+	return resp, nil
+}
+
+type processMediaJob struct {
+	MediaID uuid.UUID `json:"media_id"`
+}
+
+// processMedia strips metadata from an upload and renders its variants.
+// Images that can't be decoded fail right away, storage errors are
+// retried by the queue.
+func (cfg *apiConfig) processMedia(ctx context.Context, job processMediaJob) error {
+	m, err := cfg.dbQueries.GetMedia(ctx, job.MediaID)
+	if err != nil {
+		return err
+	}
+	if m.Status != mediaProcessing {
+		return nil
+	}
+
+	// the raw upload is of no use once processing gave up, and it still
+	// has its metadata
+	fail := func(reason error) error {
+		err := cfg.dbQueries.FailMediaProcessing(ctx, database.FailMediaProcessingParams{
+			ID:      m.ID,
+			Failure: reason.Error(),
+		})
+		if err != nil {
+			return err
+		}
+		err = cfg.mediaStore(m.StorageKey).Delete(ctx, m.StorageKey)
+		if err != nil {
+			log.Printf("Couldn't delete upload %s: %s", m.StorageKey, err)
+		}
+		return nil
+	}
+
+	err = cfg.renderMedia(ctx, m)
+	if errors.Is(err, media.ErrInvalidImage) {
+		return fail(err)
+	}
+	if err != nil && jobs.FinalAttempt(ctx) {
+		if errFail := fail(err); errFail != nil {
+			log.Printf("Couldn't mark media %s failed: %s", m.ID, errFail)
+		}
+	}
+	return err
+}
+
+func (cfg *apiConfig) renderMedia(ctx context.Context, m database.Medium) error {
+	rc, err := cfg.mediaStore(m.StorageKey).Open(ctx, m.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, maxMediaSize+1))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	res, err := media.Process(data)
+	if err != nil {
+		return err
+	}
+
+	put := func(name string, img media.Image) (string, error) {
+		key := fmt.Sprintf("%s/%s%s", m.ID, name, media.Types[img.ContentType])
+		return key, cfg.blobs.Put(ctx, key, img.ContentType, bytes.NewReader(img.Data), int64(len(img.Data)))
+	}
+
+	originalKey, err := put("original", res.Original)
+	if err != nil {
+		return err
+	}
+	variantKeys := map[string]string{}
+	for name, img := range res.Variants {
+		variantKeys[name], err = put(name, img)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	_, err = q.CompleteMediaProcessing(ctx, database.CompleteMediaProcessingParams{
+		ID:          m.ID,
+		StorageKey:  originalKey,
+		ContentType: res.Original.ContentType,
+		SizeBytes:   int64(len(res.Original.Data)),
+		Width:       int32(res.Original.Width),
+		Height:      int32(res.Original.Height),
+		Blurhash:    res.Blurhash,
+	})
+	if err != nil {
+		return err
+	}
+	for name, img := range res.Variants {
+		err = q.CreateMediaVariant(ctx, database.CreateMediaVariantParams{
+			MediaID:     m.ID,
+			Name:        name,
+			StorageKey:  variantKeys[name],
+			ContentType: img.ContentType,
+			Width:       int32(img.Width),
+			Height:      int32(img.Height),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// the raw upload still has its EXIF data, nothing points at it anymore
+	err = cfg.mediaStore(m.StorageKey).Delete(ctx, m.StorageKey)
+	if err != nil {
+		log.Printf("Couldn't delete upload %s: %s", m.StorageKey, err)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerMediaUpload(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// leave room for the multipart framing and the alt text
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaSize+1<<20)
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", err)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse upload", err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Missing file", err)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxMediaSize {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", nil)
+		return
+	}
+
+	altText := r.FormValue("alt_text")
+	if len(altText) > maxAltTextLength {
+		respondWithError(w, http.StatusBadRequest, "Alt text is too long", nil)
+		return
+	}
+
+	contentType, body, err := media.Sniff(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read file", err)
+		return
+	}
+	ext, ok := media.Types[contentType]
+	if !ok {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG, GIF and WebP images are allowed", nil)
+		return
+	}
+
+	mediaID := uuid.New()
+	m, err := cfg.dbQueries.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:          mediaID,
+		UserID:      userID,
+		StorageKey:  uploadKeyPrefix + mediaID.String() + ext,
+		ContentType: contentType,
+		SizeBytes:   header.Size,
+		AltText:     altText,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+
+	err = cfg.uploads.Put(r.Context(), m.StorageKey, contentType, body, header.Size)
+	if err != nil {
+		errFail := cfg.dbQueries.FailMediaProcessing(context.WithoutCancel(r.Context()), database.FailMediaProcessingParams{
+			ID:      m.ID,
+			Failure: "upload failed",
+		})
+		if errFail != nil {
+			log.Printf("Couldn't mark media %s failed: %s", m.ID, errFail)
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store file", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	m, err = q.SetMediaStatus(r.Context(), database.SetMediaStatusParams{
+		ID:     m.ID,
+		Status: mediaProcessing,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+
+	_, err = jobs.Enqueue(r.Context(), q, jobProcessMedia, processMediaJob{MediaID: m.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+
+	// clients poll GET /api/media/{mediaID} until it is ready or failed
+	respondWithJSON(w, http.StatusAccepted, cfg.mediaFromDB(m, nil))
+}
+
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	mediaUUID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media id", err)
+		return
+	}
+
+	m, err := cfg.dbQueries.GetMedia(r.Context(), mediaUUID)
+	if err != nil || m.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+		return
+	}
+
+	resp, err := cfg.loadMedia(r.Context(), cfg.dbQueries, []database.Medium{m})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load media", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
+
+func (cfg *apiConfig) handlerMediaUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		AltText string `json:"alt_text"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	mediaUUID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media id", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if len(params.AltText) > maxAltTextLength {
+		respondWithError(w, http.StatusBadRequest, "Alt text is too long", nil)
+		return
+	}
+
+	m, err := cfg.dbQueries.UpdateMediaAltText(r.Context(), database.UpdateMediaAltTextParams{
+		ID:      mediaUUID,
+		UserID:  userID,
+		AltText: params.AltText,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+		return
+	}
+
+	resp, err := cfg.loadMedia(r.Context(), cfg.dbQueries, []database.Medium{m})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load media", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
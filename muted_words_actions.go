@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/filters"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxMutedPhraseLength = 100
+
+type MutedWord struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Phrase    string     `json:"phrase"`
+	Action    string     `json:"action"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ChirpFilter tells a client why a chirp is collapsed
+type ChirpFilter struct {
+	Action  string   `json:"action"`
+	Matched []string `json:"matched"`
+}
+
+func mutedWordFromDB(word database.MutedWord) MutedWord {
+	resp := MutedWord{
+		ID:        word.ID,
+		CreatedAt: word.CreatedAt.Time,
+		Phrase:    word.Phrase,
+		Action:    word.Action,
+	}
+	if word.ExpiresAt.Valid {
+		resp.ExpiresAt = &word.ExpiresAt.Time
+	}
+	return resp
+}
+
+// mutedWordFilters loads the filters userID currently has in effect
+func mutedWordFilters(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]filters.Filter, error) {
+	words, err := q.ListActiveMutedWords(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var fs []filters.Filter
+	for _, word := range words {
+		fs = append(fs, filters.Filter{
+			Phrase:    word.Phrase,
+			Action:    word.Action,
+			ExpiresAt: word.ExpiresAt.Time,
+		})
+	}
+	return fs, nil
+}
+
+func (cfg *apiConfig) handlerMutedWords(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	words, err := cfg.dbQueries.ListMutedWords(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list muted words", err)
+		return
+	}
+
+	resp := []MutedWord{}
+	for _, word := range words {
+		resp = append(resp, mutedWordFromDB(word))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerMutedWordsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Phrase    string     `json:"phrase"`
+		Action    string     `json:"action"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// stored the way it is matched so the same phrase can't be added twice
+	phrase := strings.Join(filters.Tokenize(params.Phrase), " ")
+	if phrase == "" || len(phrase) > maxMutedPhraseLength {
+		respondWithError(w, http.StatusBadRequest, "Phrase must be between 1 and 100 characters", nil)
+		return
+	}
+
+	if params.Action == "" {
+		params.Action = filters.ActionHide
+	}
+	if params.Action != filters.ActionHide && params.Action != filters.ActionWarn {
+		respondWithError(w, http.StatusBadRequest, "Action must be hide or warn", nil)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "Expiry must be in the future", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+	}
+
+	word, err := cfg.dbQueries.CreateMutedWord(r.Context(), database.CreateMutedWordParams{
+		UserID:    userID,
+		Phrase:    phrase,
+		Action:    params.Action,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute word", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mutedWordFromDB(word))
+}
+
+func (cfg *apiConfig) handlerMutedWordDelete(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	wordUUID, err := uuid.Parse(r.PathValue("mutedWordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid muted word id", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteMutedWord(r.Context(), database.DeleteMutedWordParams{
+		ID:     wordUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete muted word", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find muted word", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
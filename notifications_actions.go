@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/filters"
+	"github.com/Weso1ek/chirpy/internal/notifications"
+	"github.com/Weso1ek/chirpy/internal/pagination"
+	"github.com/Weso1ek/chirpy/internal/realtime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Type       string          `json:"type"`
+	ActorID    *uuid.UUID      `json:"actor_id,omitempty"`
+	ActorCount int32           `json:"actor_count"`
+	ChirpID    *uuid.UUID      `json:"chirp_id,omitempty"`
+	Data       json.RawMessage `json:"data"`
+	ReadAt     *time.Time      `json:"read_at"`
+}
+
+func notificationFromDB(n database.Notification) Notification {
+	notification := Notification{
+		ID:         n.ID,
+		CreatedAt:  n.CreatedAt.Time,
+		UpdatedAt:  n.UpdatedAt,
+		Type:       n.Type,
+		ActorCount: n.ActorCount,
+		Data:       n.Data,
+	}
+	if n.ActorID.Valid {
+		notification.ActorID = &n.ActorID.UUID
+	}
+	if n.ChirpID.Valid {
+		notification.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		notification.ReadAt = &n.ReadAt.Time
+	}
+	return notification
+}
+
+// newNotification describes something that happened to UserID. Subject is
+// what repeated notifications are grouped on, see notifications.GroupKey.
+type newNotification struct {
+	UserID  uuid.UUID
+	Type    string
+	ActorID uuid.NullUUID
+	ChirpID uuid.NullUUID
+	Subject uuid.UUID
+	// Text is the chirp that caused it, checked against muted words
+	Text string
+	Data any
+}
+
+// notify stores n unless the user turned its type off and pushes it to the
+// user's open connections once q's transaction commits
+func notify(ctx context.Context, q *database.Queries, n newNotification) error {
+	// nobody needs to hear about their own likes and replies
+	if n.ActorID.Valid && n.ActorID.UUID == n.UserID {
+		return nil
+	}
+
+	if n.Text != "" {
+		mutedWords, err := mutedWordFilters(ctx, q, n.UserID)
+		if err != nil {
+			return err
+		}
+		if filters.Match(mutedWords, n.Text, time.Now()).Action != "" {
+			return nil
+		}
+	}
+
+	data := json.RawMessage("{}")
+	if n.Data != nil {
+		dat, err := json.Marshal(n.Data)
+		if err != nil {
+			return err
+		}
+		data = dat
+	}
+
+	notification, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:   n.UserID,
+		Type:     n.Type,
+		GroupKey: notifications.GroupKey(n.Type, n.Subject),
+		ActorID:  n.ActorID,
+		ChirpID:  n.ChirpID,
+		Data:     data,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return publishRealtime(ctx, q, realtime.NotificationsTopic(n.UserID), realtime.TypeNotification, notificationFromDB(notification))
+}
+
+func (cfg *apiConfig) handlerNotifications(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	params := database.ListNotificationsParams{
+		UserID:           userID,
+		MaxNotifications: int32(limit),
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		beforeAt, beforeID, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		params.BeforeAt = sql.NullTime{Time: beforeAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: beforeID, Valid: true}
+	}
+
+	list, err := cfg.dbQueries.ListNotifications(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list notifications", err)
+		return
+	}
+
+	unread, err := cfg.dbQueries.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count notifications", err)
+		return
+	}
+
+	resp := response{
+		Notifications: []Notification{},
+		UnreadCount:   unread,
+	}
+	for _, n := range list {
+		resp.Notifications = append(resp.Notifications, notificationFromDB(n))
+	}
+	if len(list) == limit {
+		last := list[len(list)-1]
+		resp.NextCursor = pagination.EncodeCursor(last.UpdatedAt, last.ID)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerNotificationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	notificationUUID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification id", err)
+		return
+	}
+
+	// reading twice is fine, so a missing row only means it isn't theirs
+	_, err = cfg.dbQueries.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notification read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerNotificationsReadAll(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notificationPreferences returns every type with whether it is enabled,
+// types the user never touched are on
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	prefs, err := cfg.dbQueries.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(notifications.Types))
+	for _, t := range notifications.Types {
+		enabled[t] = true
+	}
+	for _, pref := range prefs {
+		if notifications.Valid(pref.Type) {
+			enabled[pref.Type] = pref.Enabled
+		}
+	}
+	return enabled, nil
+}
+
+func (cfg *apiConfig) handlerNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load preferences", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (cfg *apiConfig) handlerNotificationPreferencesUpdate(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// only the types present are changed
+	params := map[string]bool{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	for t := range params {
+		if !notifications.Valid(t) {
+			respondWithError(w, http.StatusBadRequest, "Unknown notification type: "+t, nil)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update preferences", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	for t, enabled := range params {
+		err = q.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    t,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update preferences", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update preferences", err)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load preferences", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, prefs)
+}
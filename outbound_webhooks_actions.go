@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/webhooks"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EventID        uuid.UUID  `json:"event_id"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int32      `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+func webhookSubscriptionFromDB(s database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt.Time,
+		UpdatedAt: s.UpdatedAt.Time,
+		Url:       s.Url,
+		Events:    s.Events,
+		Active:    s.Active,
+	}
+}
+
+func (cfg *apiConfig) handlerWebhookSubscriptionsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Url    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	callback, err := url.Parse(params.Url)
+	if err != nil || callback.Host == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid callback url", err)
+		return
+	}
+	// plain http is only allowed locally so payloads aren't sent in the clear
+	if callback.Scheme != "https" && !(cfg.platform == "dev" && callback.Scheme == "http") {
+		respondWithError(w, http.StatusBadRequest, "Callback url must use https", nil)
+		return
+	}
+	// the worker refuses these too, checking here gives a useful error
+	if cfg.platform != "dev" {
+		err = webhooks.CheckCallbackHost(r.Context(), callback.Hostname())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Callback url must point at a public address", err)
+			return
+		}
+	}
+
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "No events selected", nil)
+		return
+	}
+	for _, event := range params.Events {
+		if !slices.Contains(webhooks.Events, event) {
+			respondWithError(w, http.StatusBadRequest, "Unknown event "+event, nil)
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create signing secret", err)
+		return
+	}
+
+	subscription, err := cfg.dbQueries.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		UserID: userID,
+		Url:    callback.String(),
+		Events: params.Events,
+		Secret: secret,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+
+	// the secret is only ever shown once
+	resp := webhookSubscriptionFromDB(subscription)
+	resp.Secret = subscription.Secret
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	subscriptions, err := cfg.dbQueries.ListWebhookSubscriptionsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list webhooks", err)
+		return
+	}
+
+	subscriptionsResp := []WebhookSubscription{}
+	for _, s := range subscriptions {
+		subscriptionsResp = append(subscriptionsResp, webhookSubscriptionFromDB(s))
+	}
+
+	respondWithJSON(w, http.StatusOK, subscriptionsResp)
+}
+
+func (cfg *apiConfig) handlerWebhookSubscriptionDelete(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	webhookUUID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook id", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteWebhookSubscription(r.Context(), database.DeleteWebhookSubscriptionParams{
+		ID:     webhookUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete webhook", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	webhookUUID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook id", err)
+		return
+	}
+
+	subscription, err := cfg.dbQueries.GetWebhookSubscription(r.Context(), webhookUUID)
+	if err != nil || subscription.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook", err)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	deliveries, err := cfg.dbQueries.ListWebhookDeliveriesBySubscription(r.Context(), database.ListWebhookDeliveriesBySubscriptionParams{
+		SubscriptionID: webhookUUID,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list deliveries", err)
+		return
+	}
+
+	deliveriesResp := []WebhookDelivery{}
+	for _, d := range deliveries {
+		delivery := WebhookDelivery{
+			ID:             d.ID,
+			CreatedAt:      d.CreatedAt.Time,
+			EventID:        d.EventID,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			NextAttemptAt:  d.NextAttemptAt,
+			LastStatusCode: d.LastStatusCode.Int32,
+			LastError:      d.LastError.String,
+		}
+		if d.DeliveredAt.Valid {
+			delivery.DeliveredAt = &d.DeliveredAt.Time
+		}
+		deliveriesResp = append(deliveriesResp, delivery)
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveriesResp)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// pinFirst puts pinned in front of the first page of an author's chirps
+// and drops any copy of them further down, they're flagged as pinned
+func pinFirst(chirps []Chirp, pinned []Chirp) []Chirp {
+	resp := make([]Chirp, 0, len(pinned)+len(chirps))
+	seen := map[uuid.UUID]bool{}
+	for _, chirp := range pinned {
+		chirp.Pinned = true
+		seen[chirp.ID] = true
+		resp = append(resp, chirp)
+	}
+	for _, chirp := range chirps {
+		if !seen[chirp.ID] {
+			resp = append(resp, chirp)
+		}
+	}
+	return resp
+}
+
+// pinnedChirps loads the chirps authorID pinned that viewer can see
+func (cfg *apiConfig) pinnedChirps(ctx context.Context, authorID uuid.UUID, viewer uuid.NullUUID) ([]Chirp, error) {
+	ids, err := cfg.dbQueries.ListPinnedChirpIDs(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := []Chirp{}
+	for _, id := range ids {
+		chirp, err := cfg.dbQueries.GetChirpForViewer(ctx, database.GetChirpForViewerParams{
+			ID:       id,
+			ViewerID: viewer,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		pinned := chirpFromDB(chirp)
+		pinned.Pinned = true
+		resp = append(resp, pinned)
+	}
+
+	err = cfg.loadChirpDetails(ctx, cfg.dbQueries, viewer, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) handlerPinChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+		return
+	}
+
+	pinned, err := cfg.dbQueries.IsChirpPinned(r.Context(), database.IsChirpPinnedParams{
+		UserID:  userID,
+		ChirpID: chirpUUID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+	if pinned {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load entitlements", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	// concurrent pins would each count the same pins and all get in, so
+	// they take turns on the user row
+	err = q.LockUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+
+	// pins above a lowered limit are kept, new ones wait until there's room
+	added, err := q.PinChirp(r.Context(), database.PinChirpParams{
+		UserID:    userID,
+		ChirpID:   chirpUUID,
+		MaxPinned: int32(limits.PinnedChirps),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+	if added == 0 {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can pin at most %d chirps", limits.PinnedChirps), nil)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnpinChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpUUID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unpin chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
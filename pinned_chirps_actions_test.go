@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPinFirst(t *testing.T) {
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	names := map[uuid.UUID]string{a: "a", b: "b", c: "c", d: "d"}
+
+	tests := []struct {
+		name       string
+		chirps     []uuid.UUID
+		pinned     []uuid.UUID
+		want       []string
+		wantPinned int
+	}{
+		{name: "nothing pinned", chirps: []uuid.UUID{a, b}, want: []string{"a", "b"}},
+		{name: "pinned go first in pin order", chirps: []uuid.UUID{a, b}, pinned: []uuid.UUID{d, c}, want: []string{"d", "c", "a", "b"}, wantPinned: 2},
+		{name: "pinned copy on the page is dropped", chirps: []uuid.UUID{a, b, c}, pinned: []uuid.UUID{b}, want: []string{"b", "a", "c"}, wantPinned: 1},
+		{name: "empty page", pinned: []uuid.UUID{a}, want: []string{"a"}, wantPinned: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chirps, pinned []Chirp
+			for _, id := range tt.chirps {
+				chirps = append(chirps, Chirp{ID: id})
+			}
+			for _, id := range tt.pinned {
+				pinned = append(pinned, Chirp{ID: id})
+			}
+
+			got := pinFirst(chirps, pinned)
+			if len(got) != len(tt.want) {
+				t.Fatalf("pinFirst() returned %d chirps, want %d", len(got), len(tt.want))
+			}
+			for i, chirp := range got {
+				if names[chirp.ID] != tt.want[i] {
+					t.Errorf("chirp %d = %s, want %s", i, names[chirp.ID], tt.want[i])
+				}
+				if chirp.Pinned != (i < tt.wantPinned) {
+					t.Errorf("chirp %s Pinned = %v", names[chirp.ID], chirp.Pinned)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/jobs"
+	"github.com/Weso1ek/chirpy/internal/polls"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PollOption struct {
+	Text string `json:"text"`
+	// Votes is left out while the results are hidden from the viewer
+	Votes *int64 `json:"votes,omitempty"`
+}
+
+type Poll struct {
+	Multiple bool         `json:"multiple"`
+	ClosesAt time.Time    `json:"closes_at"`
+	Closed   bool         `json:"closed"`
+	Options  []PollOption `json:"options"`
+	// Voted holds the positions the viewer picked, results are shown once
+	// there are some or the poll is closed
+	Voted  []int  `json:"voted"`
+	Voters *int64 `json:"voters,omitempty"`
+}
+
+// pollInput is a poll on a chirp being posted
+type pollInput struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+	Multiple bool      `json:"multiple"`
+}
+
+type closePollJob struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// checkPollInput validates a poll on a chirp posted at postedAt
+func checkPollInput(in *pollInput, postedAt time.Time) error {
+	err := polls.ValidateOptions(in.Options)
+	if err == nil {
+		err = polls.ValidateClosesAt(in.ClosesAt, postedAt)
+	}
+	if err != nil {
+		return invalidChirp("Invalid poll: " + err.Error())
+	}
+	return nil
+}
+
+// createPoll adds in to chirpID and schedules storing its final tallies
+// when it closes
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, in pollInput) error {
+	closesAt := in.ClosesAt.UTC()
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		Multiple: in.Multiple,
+		ClosesAt: closesAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	texts := make([]string, 0, len(in.Options))
+	for _, option := range in.Options {
+		texts = append(texts, strings.TrimSpace(option))
+	}
+	err = q.CreatePollOptions(ctx, database.CreatePollOptionsParams{
+		ChirpID: chirpID,
+		Texts:   texts,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = jobs.Enqueue(ctx, q, jobClosePoll, closePollJob{ChirpID: chirpID}, jobs.RunAt(closesAt))
+	return err
+}
+
+// chirpPolls loads the polls on chirpIDs as viewer sees them
+func chirpPolls(ctx context.Context, q *database.Queries, viewer uuid.NullUUID, chirpIDs ...uuid.UUID) (map[uuid.UUID]*Poll, error) {
+	resp := map[uuid.UUID]*Poll{}
+	if len(chirpIDs) == 0 {
+		return resp, nil
+	}
+
+	list, err := q.ListPolls(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return resp, nil
+	}
+	pollIDs := make([]uuid.UUID, 0, len(list))
+	for _, p := range list {
+		pollIDs = append(pollIDs, p.ChirpID)
+	}
+
+	options, err := q.ListPollOptions(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+	byPoll := map[uuid.UUID][]database.ListPollOptionsRow{}
+	for _, option := range options {
+		byPoll[option.ChirpID] = append(byPoll[option.ChirpID], option)
+	}
+
+	voted := map[uuid.UUID][]int{}
+	if viewer.Valid {
+		votes, err := q.ListPollVotesByUser(ctx, database.ListPollVotesByUserParams{
+			UserID:   viewer.UUID,
+			ChirpIds: pollIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			for _, choice := range vote.Choices {
+				voted[vote.ChirpID] = append(voted[vote.ChirpID], int(choice))
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, p := range list {
+		poll := &Poll{
+			Multiple: p.Multiple,
+			ClosesAt: p.ClosesAt,
+			Closed:   !now.Before(p.ClosesAt),
+			Options:  []PollOption{},
+			Voted:    voted[p.ChirpID],
+		}
+		if poll.Voted == nil {
+			poll.Voted = []int{}
+		}
+
+		visible := polls.ResultsVisible(len(poll.Voted) > 0, p.ClosesAt, now)
+		if visible {
+			poll.Voters = &p.Voters
+		}
+		for _, option := range byPoll[p.ChirpID] {
+			resp := PollOption{Text: option.Text}
+			if visible {
+				votes := option.Votes
+				if option.FinalVotes.Valid {
+					votes = option.FinalVotes.Int64
+				}
+				resp.Votes = &votes
+			}
+			poll.Options = append(poll.Options, resp)
+		}
+		resp[p.ChirpID] = poll
+	}
+	return resp, nil
+}
+
+// closePoll stores the final tallies of a poll, votes are already refused
+// from its closing time on
+func (cfg *apiConfig) closePoll(ctx context.Context, job closePollJob) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	closed, err := q.ClosePoll(ctx, job.ChirpID)
+	if err != nil {
+		return err
+	}
+	if closed == 0 {
+		return nil
+	}
+
+	err = q.StorePollTallies(ctx, job.ChirpID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) handlerPollVote(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Choices []int `json:"choices"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	viewer := uuid.NullUUID{UUID: userID, Valid: true}
+	_, err = cfg.dbQueries.GetChirpForViewer(r.Context(), database.GetChirpForViewerParams{
+		ID:       chirpUUID,
+		ViewerID: viewer,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	poll, err := cfg.dbQueries.GetPoll(r.Context(), chirpUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp has no poll", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load poll", err)
+		return
+	}
+	if !time.Now().UTC().Before(poll.ClosesAt) {
+		respondWithError(w, http.StatusConflict, "Poll is closed", nil)
+		return
+	}
+
+	err = polls.ValidateChoices(params.Choices, int(poll.OptionCount), poll.Multiple)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid choices: "+err.Error(), err)
+		return
+	}
+
+	choices := make([]int32, 0, len(params.Choices))
+	for _, choice := range params.Choices {
+		choices = append(choices, int32(choice))
+	}
+	voted, err := cfg.dbQueries.VotePoll(r.Context(), database.VotePollParams{
+		UserID:  userID,
+		Choices: choices,
+		ChirpID: chirpUUID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't vote", err)
+		return
+	}
+	// closing in between is rare enough to report like a second vote
+	if voted == 0 {
+		respondWithError(w, http.StatusConflict, "You already voted", nil)
+		return
+	}
+
+	resp, err := chirpPolls(r.Context(), cfg.dbQueries, viewer, chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load poll", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp[chirpUUID])
+}
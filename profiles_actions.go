@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/handles"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	handleRedirectGrace  = 30 * 24 * time.Hour
+	maxDisplayNameLength = 50
+	maxBioLength         = 300
+	maxWebsiteLength     = 200
+)
+
+var errHandleTaken = errors.New("handle is already taken")
+
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	Website     string    `json:"website"`
+	Avatar      *Media    `json:"avatar"`
+	IsPrivate   bool      `json:"is_private"`
+	// PinnedChirps only holds the ones the viewer can see
+	PinnedChirps []Chirp `json:"pinned_chirps"`
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// validWebsite accepts absolute http and https URLs
+func validWebsite(website string) bool {
+	u, err := url.Parse(website)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// saveProfile writes params within q's transaction. Moving away from an
+// old handle leaves a redirect from it for handleRedirectGrace, and until
+// that expires nobody else can claim it.
+func saveProfile(ctx context.Context, q *database.Queries, user database.User, params database.UpdateUserProfileParams) (database.User, error) {
+	if params.Handle.Valid && !strings.EqualFold(params.Handle.String, user.Handle.String) {
+		redirect, err := q.GetHandleRedirect(ctx, params.Handle.String)
+		if err == nil && redirect.UserID != user.ID {
+			return database.User{}, errHandleTaken
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, err
+		}
+		// taking back your own old handle drops its redirect
+		err = q.DeleteHandleRedirect(ctx, params.Handle.String)
+		if err != nil {
+			return database.User{}, err
+		}
+
+		if user.Handle.Valid {
+			err = q.CreateHandleRedirect(ctx, database.CreateHandleRedirectParams{
+				OldHandle: user.Handle.String,
+				UserID:    user.ID,
+				ExpiresAt: time.Now().UTC().Add(handleRedirectGrace),
+			})
+			if err != nil {
+				return database.User{}, err
+			}
+		}
+	}
+
+	updated, err := q.UpdateUserProfile(ctx, params)
+	if isUniqueViolation(err) {
+		return database.User{}, errHandleTaken
+	}
+	return updated, err
+}
+
+func (cfg *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	handle := handles.Normalize(r.PathValue("handle"))
+
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByHandle(r.Context(), handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		redirect, err := cfg.dbQueries.GetHandleRedirect(r.Context(), handle)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		user, err = cfg.dbQueries.GetUser(r.Context(), redirect.UserID)
+		if err != nil || !user.Handle.Valid {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		http.Redirect(w, r, "/api/users/"+url.PathEscape(user.Handle.String), http.StatusMovedPermanently)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load user", err)
+		return
+	}
+
+	if viewer.Valid {
+		blocked, err := cfg.isBlocked(r.Context(), viewer.UUID, user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load user", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user", nil)
+			return
+		}
+	}
+
+	resp := Profile{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt.Time,
+		Handle:      user.Handle.String,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Website:     user.Website,
+		IsPrivate:   user.IsPrivate,
+	}
+	if user.AvatarMediaID.Valid {
+		avatars, err := cfg.avatars(r.Context(), cfg.dbQueries, user.AvatarMediaID.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load avatar", err)
+			return
+		}
+		if avatar, ok := avatars[user.AvatarMediaID.UUID]; ok {
+			resp.Avatar = &avatar
+		}
+	}
+
+	resp.PinnedChirps, err = cfg.pinnedChirps(r.Context(), user.ID, viewer)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load pinned chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
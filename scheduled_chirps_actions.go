@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/jobs"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// a scheduled chirp is a draft until it gets a publish_at, and failed when
+// publishing it didn't work out; published ones are removed
+const (
+	scheduledChirpDraft     = "draft"
+	scheduledChirpScheduled = "scheduled"
+)
+
+// how often a due chirp of a deleted account checks whether it was restored
+const deletedAuthorRecheck = time.Hour
+
+type ScheduledChirp struct {
+	ID         uuid.UUID   `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Body       string      `json:"body"`
+	ReplyToID  *uuid.UUID  `json:"reply_to_id,omitempty"`
+	Visibility string      `json:"visibility"`
+	Mentions   []uuid.UUID `json:"mentions"`
+	MediaIDs   []uuid.UUID `json:"media_ids"`
+	Poll       *pollInput  `json:"poll"`
+	PublishAt  *time.Time  `json:"publish_at"`
+	Status     string      `json:"status"`
+	Failure    string      `json:"failure,omitempty"`
+}
+
+type publishChirpJob struct {
+	ScheduledChirpID uuid.UUID `json:"scheduled_chirp_id"`
+	PublishAt        time.Time `json:"publish_at"`
+}
+
+// scheduledPoll reads the poll stored with a scheduled chirp, nil when
+// there is none
+func scheduledPoll(scheduled database.ScheduledChirp) (*pollInput, error) {
+	var poll *pollInput
+	err := json.Unmarshal(scheduled.Poll, &poll)
+	return poll, err
+}
+
+func scheduledChirpFromDB(scheduled database.ScheduledChirp) ScheduledChirp {
+	resp := ScheduledChirp{
+		ID:         scheduled.ID,
+		CreatedAt:  scheduled.CreatedAt.Time,
+		UpdatedAt:  scheduled.UpdatedAt.Time,
+		Body:       scheduled.Body,
+		Visibility: scheduled.Visibility,
+		Mentions:   nonNilIDs(scheduled.Mentions),
+		MediaIDs:   nonNilIDs(scheduled.MediaIds),
+		Status:     scheduled.Status,
+		Failure:    scheduled.Failure,
+	}
+	if scheduled.ReplyToID.Valid {
+		resp.ReplyToID = &scheduled.ReplyToID.UUID
+	}
+	if scheduled.PublishAt.Valid {
+		resp.PublishAt = &scheduled.PublishAt.Time
+	}
+	// only ever written from a pollInput
+	resp.Poll, _ = scheduledPoll(scheduled)
+	return resp
+}
+
+// nonNilIDs keeps arrays from being stored or rendered as null
+func nonNilIDs(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
+
+// schedulePublish checks publishAt and returns what to store for it. The
+// job is enqueued by the caller in the same transaction.
+func schedulePublish(draft bool, publishAt *time.Time) (sql.NullTime, string, error) {
+	if draft {
+		if publishAt != nil {
+			return sql.NullTime{}, "", invalidChirp("A draft can't have a publish_at")
+		}
+		return sql.NullTime{}, scheduledChirpDraft, nil
+	}
+	// the database keeps microseconds, jobs compare against what was stored
+	at := publishAt.UTC().Truncate(time.Microsecond)
+	if !at.After(time.Now().UTC()) {
+		return sql.NullTime{}, "", invalidChirp("publish_at must be in the future")
+	}
+	return sql.NullTime{Time: at, Valid: true}, scheduledChirpScheduled, nil
+}
+
+// postingTime is when a scheduled chirp is expected to go out, polls are
+// checked against it. Drafts are checked against now and again once
+// they're published.
+func postingTime(publishAt sql.NullTime) time.Time {
+	if publishAt.Valid {
+		return publishAt.Time
+	}
+	return time.Now().UTC()
+}
+
+func enqueuePublish(ctx context.Context, q *database.Queries, scheduled database.ScheduledChirp) error {
+	if scheduled.Status != scheduledChirpScheduled {
+		return nil
+	}
+	_, err := jobs.Enqueue(ctx, q, jobPublishChirp, publishChirpJob{
+		ScheduledChirpID: scheduled.ID,
+		PublishAt:        scheduled.PublishAt.Time,
+	}, jobs.RunAt(scheduled.PublishAt.Time))
+	return err
+}
+
+// scheduleChirp saves in as a draft or for publishing at publishAt. Only
+// the checks that don't depend on the time of posting run now, everything
+// runs again when it's published.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID, in chirpInput, draft bool, publishAt *time.Time) {
+	limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load entitlements", err)
+		return
+	}
+	at, status, err := schedulePublish(draft, publishAt)
+	if err != nil {
+		respondWithChirpError(w, err, "Couldn't schedule chirp")
+		return
+	}
+
+	err = checkChirpInput(limits, &in, postingTime(at))
+	if err != nil {
+		respondWithChirpError(w, err, "Couldn't schedule chirp")
+		return
+	}
+
+	poll, err := json.Marshal(in.Poll)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	replyToID := uuid.NullUUID{}
+	if in.ReplyToID != nil {
+		replyToID = uuid.NullUUID{UUID: *in.ReplyToID, Valid: true}
+	}
+	scheduled, err := q.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+		UserID:     userID,
+		Body:       in.Body,
+		ReplyToID:  replyToID,
+		Visibility: in.Visibility,
+		Mentions:   nonNilIDs(in.Mentions),
+		MediaIds:   nonNilIDs(in.MediaIDs),
+		PublishAt:  at,
+		Status:     status,
+		Poll:       poll,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+
+	err = enqueuePublish(r.Context(), q, scheduled)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, scheduledChirpFromDB(scheduled))
+}
+
+// publishScheduledChirp posts a scheduled chirp once it's due. Chirps
+// that fail the checks are marked failed right away, anything else is
+// retried and only marked failed on the last attempt.
+func (cfg *apiConfig) publishScheduledChirp(ctx context.Context, job publishChirpJob) error {
+	err := cfg.publishDue(ctx, job)
+
+	var inputErr *chirpInputError
+	if errors.As(err, &inputErr) && inputErr.Status != http.StatusTooManyRequests {
+		return cfg.dbQueries.FailScheduledChirp(ctx, database.FailScheduledChirpParams{
+			ID:      job.ScheduledChirpID,
+			Failure: inputErr.Message,
+		})
+	}
+	if err != nil && jobs.FinalAttempt(ctx) {
+		errFail := cfg.dbQueries.FailScheduledChirp(ctx, database.FailScheduledChirpParams{
+			ID:      job.ScheduledChirpID,
+			Failure: "Couldn't publish chirp",
+		})
+		if errFail != nil {
+			log.Printf("Couldn't mark scheduled chirp %s failed: %s", job.ScheduledChirpID, errFail)
+		}
+	}
+	return err
+}
+
+func (cfg *apiConfig) publishDue(ctx context.Context, job publishChirpJob) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	// cancelled, turned back into a draft or moved to a later time, whose
+	// job takes over
+	scheduled, err := q.LockScheduledChirp(ctx, job.ScheduledChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if scheduled.Status != scheduledChirpScheduled || scheduled.PublishAt.Time.After(job.PublishAt) {
+		return nil
+	}
+
+	// the account can still be restored, so the chirp waits instead of
+	// failing; purging it removes the chirp along with the account
+	_, err = q.GetUser(ctx, scheduled.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = jobs.Enqueue(ctx, q, jobPublishChirp, job, jobs.RunAt(time.Now().UTC().Add(deletedAuthorRecheck)))
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	in := chirpInput{
+		Body:       scheduled.Body,
+		Visibility: scheduled.Visibility,
+		Mentions:   scheduled.Mentions,
+		MediaIDs:   scheduled.MediaIds,
+	}
+	if scheduled.ReplyToID.Valid {
+		in.ReplyToID = &scheduled.ReplyToID.UUID
+	}
+	in.Poll, err = scheduledPoll(scheduled)
+	if err != nil {
+		return err
+	}
+	_, err = q.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{
+		ID:     scheduled.ID,
+		UserID: scheduled.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// createChirp publishes the chirp event, which has to come last
+	_, err = cfg.createChirp(ctx, q, scheduled.UserID, in)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) handlerScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	list, err := cfg.dbQueries.ListScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list scheduled chirps", err)
+		return
+	}
+
+	resp := []ScheduledChirp{}
+	for _, scheduled := range list {
+		resp = append(resp, scheduledChirpFromDB(scheduled))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerScheduledChirpUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body       *string      `json:"body"`
+		Visibility *string      `json:"visibility"`
+		Mentions   *[]uuid.UUID `json:"mentions"`
+		MediaIDs   *[]uuid.UUID `json:"media_ids"`
+		// null removes the poll, leaving it out keeps the current one
+		Poll json.RawMessage `json:"poll"`
+		// a new publish_at reschedules, draft unschedules, leaving both out
+		// keeps the current state
+		Draft     bool       `json:"draft"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	scheduledUUID, err := uuid.Parse(r.PathValue("scheduledChirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scheduled chirp id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	scheduled, err := cfg.dbQueries.GetScheduledChirp(r.Context(), database.GetScheduledChirpParams{
+		ID:     scheduledUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp", err)
+		return
+	}
+
+	in := chirpInput{
+		Body:       scheduled.Body,
+		Visibility: scheduled.Visibility,
+		Mentions:   scheduled.Mentions,
+		MediaIDs:   scheduled.MediaIds,
+	}
+	if params.Body != nil {
+		in.Body = *params.Body
+	}
+	if params.Visibility != nil {
+		in.Visibility = *params.Visibility
+	}
+	if params.Mentions != nil {
+		in.Mentions = *params.Mentions
+	}
+	if params.MediaIDs != nil {
+		in.MediaIDs = *params.MediaIDs
+	}
+	in.Poll, err = scheduledPoll(scheduled)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load scheduled chirp", err)
+		return
+	}
+	if len(params.Poll) > 0 {
+		in.Poll = nil
+		err = json.Unmarshal(params.Poll, &in.Poll)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid poll", err)
+			return
+		}
+	}
+
+	// failed chirps stay failed until they're rescheduled
+	at, status := scheduled.PublishAt, scheduled.Status
+	if params.Draft || params.PublishAt != nil {
+		at, status, err = schedulePublish(params.Draft, params.PublishAt)
+		if err != nil {
+			respondWithChirpError(w, err, "Couldn't update scheduled chirp")
+			return
+		}
+	}
+
+	limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load entitlements", err)
+		return
+	}
+	err = checkChirpInput(limits, &in, postingTime(at))
+	if err != nil {
+		respondWithChirpError(w, err, "Couldn't update scheduled chirp")
+		return
+	}
+
+	poll, err := json.Marshal(in.Poll)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update scheduled chirp", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update scheduled chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	updated, err := q.UpdateScheduledChirp(r.Context(), database.UpdateScheduledChirpParams{
+		ID:         scheduled.ID,
+		UserID:     userID,
+		Body:       in.Body,
+		Visibility: in.Visibility,
+		Mentions:   nonNilIDs(in.Mentions),
+		MediaIds:   nonNilIDs(in.MediaIDs),
+		PublishAt:  at,
+		Status:     status,
+		Poll:       poll,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update scheduled chirp", err)
+		return
+	}
+
+	// the job for the old time finds publish_at moved and leaves it alone
+	if params.PublishAt != nil {
+		err = enqueuePublish(r.Context(), q, updated)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update scheduled chirp", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update scheduled chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scheduledChirpFromDB(updated))
+}
+
+func (cfg *apiConfig) handlerScheduledChirpDelete(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	scheduledUUID, err := uuid.Parse(r.PathValue("scheduledChirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scheduled chirp id", err)
+		return
+	}
+
+	// a pending job finds nothing to publish
+	deleted, err := cfg.dbQueries.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     scheduledUUID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't cancel scheduled chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,15 +1,95 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, visibility)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
 RETURNING *;
 
 -- name: ListChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirp :one
 SELECT * FROM chirps
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: GetChirpWithDeleted :one
+SELECT * FROM chirps
 WHERE id = $1;
 
 -- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1;
+UPDATE chirps SET deleted_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps SET deleted_at = NULL
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at > sqlc.arg(cutoff)::timestamp
+RETURNING *;
+
+-- name: ListDeletedChirps :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at > sqlc.arg(cutoff)::timestamp
+  AND (
+    sqlc.narg(before_at)::timestamp IS NULL
+    OR (deleted_at, id) < (sqlc.narg(before_at)::timestamp, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY deleted_at DESC, id DESC
+LIMIT sqlc.arg(max_chirps);
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= sqlc.arg(cutoff)::timestamp;
+
+-- name: UpdateChirp :one
+UPDATE chirps SET body = $2,
+visibility = $3,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountChirpsByUserSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+  AND created_at > $2;
+
+-- name: GetChirpForViewer :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg(id)
+  AND chirp_visible_to(chirps, sqlc.narg(viewer_id)::uuid);
+
+-- name: ListChirpsForViewer :many
+SELECT * FROM chirps
+WHERE chirp_visible_to(chirps, sqlc.narg(viewer_id)::uuid)
+  AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)::uuid)
+  -- an author's pinned chirps are listed ahead of the first page instead
+  AND NOT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE pinned_chirps.chirp_id = chirps.id
+      AND pinned_chirps.user_id = sqlc.narg(author_id)::uuid
+  )
+  AND (
+    chirps.visibility <> 'unlisted'
+    OR sqlc.narg(author_id)::uuid IS NOT NULL
+    OR chirps.user_id = sqlc.narg(viewer_id)::uuid
+  )
+  AND (
+    sqlc.narg(after_at)::timestamp IS NULL
+    OR (sqlc.arg(newest_first)::bool AND (chirps.created_at, chirps.id) < (sqlc.narg(after_at)::timestamp, sqlc.narg(after_id)::uuid))
+    OR (NOT sqlc.arg(newest_first)::bool AND (chirps.created_at, chirps.id) > (sqlc.narg(after_at)::timestamp, sqlc.narg(after_id)::uuid))
+  )
+ORDER BY
+  CASE WHEN sqlc.arg(newest_first)::bool THEN created_at END DESC,
+  CASE WHEN sqlc.arg(newest_first)::bool THEN id END DESC,
+  created_at ASC,
+  id ASC
+-- NULL lists everything, the unpaginated listing
+LIMIT sqlc.narg(max_chirps);
+
+-- name: ChirpReadableBy :one
+SELECT COALESCE(chirp_readable_by(chirps, sqlc.narg(viewer_id)::uuid), false)::bool AS readable
+FROM chirps
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,49 @@
+-- name: CreateBookmarkFolder :one
+INSERT INTO bookmark_folders (id, created_at, updated_at, user_id, name)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: GetBookmarkFolder :one
+SELECT * FROM bookmark_folders
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListBookmarkFolders :many
+SELECT * FROM bookmark_folders
+WHERE user_id = $1
+ORDER BY LOWER(name);
+
+-- name: RenameBookmarkFolder :one
+UPDATE bookmark_folders SET name = $3,
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteBookmarkFolder :execrows
+DELETE FROM bookmark_folders
+WHERE id = $1 AND user_id = $2;
+
+-- name: SaveBookmark :one
+INSERT INTO bookmarks (user_id, chirp_id, folder_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id)
+DO UPDATE SET folder_id = EXCLUDED.folder_id
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+SELECT bookmarks.user_id, bookmarks.chirp_id, bookmarks.folder_id, bookmarks.created_at,
+    sqlc.embed(chirps),
+    chirp_visible_to(chirps, bookmarks.user_id)::bool AS available
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(folder_id)::uuid IS NULL OR bookmarks.folder_id = sqlc.narg(folder_id)::uuid)
+  AND (
+    sqlc.narg(before_at)::timestamp IS NULL
+    OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(before_at)::timestamp, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(max_bookmarks);
@@ -0,0 +1,12 @@
+-- name: NotifyChirpEvent :exec
+WITH ordered AS (
+    -- held until commit, so ids are handed out in the order events become
+    -- visible and a resumed stream can't skip one that committed late
+    SELECT pg_advisory_xact_lock(hashtext('chirp_events'))
+)
+SELECT pg_notify('chirp_events', json_build_object(
+    'id', nextval('chirp_event_seq'),
+    'type', sqlc.arg(event_type)::text,
+    'chirp_id', sqlc.arg(chirp_id)::uuid
+)::text)
+FROM ordered;
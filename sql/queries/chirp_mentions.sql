@@ -0,0 +1,10 @@
+-- name: CreateChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT sqlc.arg(chirp_id)::uuid, users.id FROM users
+WHERE users.id = ANY(sqlc.arg(user_ids)::uuid[])
+  AND users.deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+-- name: ListChirpMentions :many
+SELECT user_id FROM chirp_mentions
+WHERE chirp_id = $1;
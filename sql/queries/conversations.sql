@@ -0,0 +1,86 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, is_group, direct_key)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: GetConversationByDirectKey :one
+SELECT * FROM conversations
+WHERE direct_key = $1;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: GetConversationForParticipant :one
+SELECT conversations.* FROM conversations
+JOIN conversation_participants
+  ON conversation_participants.conversation_id = conversations.id
+WHERE conversations.id = $1
+  AND conversation_participants.user_id = $2
+  -- a 1:1 thread goes away with the other account
+  AND NOT EXISTS (
+    SELECT 1 FROM conversation_participants AS other
+    JOIN users ON users.id = other.user_id
+    WHERE other.conversation_id = conversations.id
+      AND NOT conversations.is_group
+      AND users.deleted_at IS NOT NULL
+  );
+
+-- name: ListConversations :many
+SELECT conversations.*,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+          AND messages.sender_id <> conversation_participants.user_id
+          AND messages.deleted_at IS NULL
+          AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = messages.sender_id AND users.deleted_at IS NOT NULL
+          )
+          AND (
+            conversation_participants.last_read_at IS NULL
+            OR messages.created_at > conversation_participants.last_read_at
+          )
+    ) AS unread_count
+FROM conversations
+JOIN conversation_participants
+  ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = $1
+  -- a 1:1 thread goes away with the other account
+  AND NOT EXISTS (
+    SELECT 1 FROM conversation_participants AS other
+    JOIN users ON users.id = other.user_id
+    WHERE other.conversation_id = conversations.id
+      AND NOT conversations.is_group
+      AND users.deleted_at IS NOT NULL
+  )
+ORDER BY conversations.updated_at DESC;
+
+-- name: ListConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = conversation_participants.user_id AND users.deleted_at IS NOT NULL
+  )
+ORDER BY joined_at ASC;
+
+-- name: ListParticipantsOfUserConversations :many
+SELECT * FROM conversation_participants
+WHERE conversation_id IN (
+    SELECT conversation_id FROM conversation_participants AS mine
+    WHERE mine.user_id = $1
+)
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = conversation_participants.user_id AND users.deleted_at IS NOT NULL
+  )
+ORDER BY joined_at ASC;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
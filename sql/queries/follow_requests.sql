@@ -0,0 +1,23 @@
+-- name: CreateFollowRequest :execrows
+INSERT INTO follow_requests (requester_id, target_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollowRequest :execrows
+DELETE FROM follow_requests
+WHERE requester_id = $1 AND target_id = $2;
+
+-- name: ListFollowRequests :many
+SELECT * FROM follow_requests
+WHERE target_id = $1
+ORDER BY created_at ASC;
+
+-- name: AcceptAllFollowRequests :execrows
+WITH accepted AS (
+    DELETE FROM follow_requests
+    WHERE target_id = $1
+    RETURNING requester_id, target_id
+)
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT requester_id, target_id, NOW() FROM accepted
+ON CONFLICT DO NOTHING;
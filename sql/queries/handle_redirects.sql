@@ -0,0 +1,19 @@
+-- name: GetHandleRedirect :one
+SELECT * FROM handle_redirects
+WHERE LOWER(old_handle) = LOWER($1)
+  AND expires_at > NOW();
+
+-- name: CreateHandleRedirect :exec
+INSERT INTO handle_redirects (old_handle, user_id, created_at, expires_at)
+VALUES ($1, $2, NOW(), $3)
+ON CONFLICT (LOWER(old_handle)) DO UPDATE SET user_id = EXCLUDED.user_id,
+created_at = EXCLUDED.created_at,
+expires_at = EXCLUDED.expires_at;
+
+-- name: DeleteHandleRedirect :exec
+DELETE FROM handle_redirects
+WHERE LOWER(old_handle) = LOWER($1);
+
+-- name: DeleteExpiredHandleRedirects :execrows
+DELETE FROM handle_redirects
+WHERE expires_at <= $1;
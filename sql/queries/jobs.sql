@@ -0,0 +1,45 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+RETURNING *;
+
+-- name: ClaimJobs :many
+UPDATE jobs SET status = 'running',
+attempts = attempts + 1,
+locked_until = sqlc.arg(locked_until),
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE jobs.kind = sqlc.arg(kind)
+      AND (
+        (jobs.status = 'pending' AND jobs.run_at <= NOW())
+        OR (jobs.status = 'running' AND jobs.locked_until < NOW())
+      )
+    ORDER BY jobs.run_at ASC
+    LIMIT sqlc.arg(max_jobs)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :execrows
+UPDATE jobs SET status = 'done',
+locked_until = NULL,
+finished_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $2;
+
+-- name: RetryJob :execrows
+UPDATE jobs SET status = 'pending',
+run_at = $2,
+last_error = $3,
+locked_until = NULL,
+updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $4;
+
+-- name: FailJob :execrows
+UPDATE jobs SET status = 'failed',
+last_error = $2,
+locked_until = NULL,
+finished_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $3;
@@ -0,0 +1,17 @@
+-- name: CreateMagicLink :one
+INSERT INTO magic_links (token_hash, created_at, user_id, nonce_hash, expires_at)
+VALUES (
+       $1,
+       NOW(),
+       $2,
+       $3,
+       $4
+   )
+RETURNING *;
+
+-- name: ConsumeMagicLink :one
+UPDATE magic_links SET used_at = NOW()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > NOW()
+RETURNING *;
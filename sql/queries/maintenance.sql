@@ -0,0 +1,26 @@
+-- name: DeleteStaleRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < sqlc.arg(cutoff)::timestamp
+   OR revoked_at < sqlc.arg(cutoff)::timestamp;
+
+-- name: DeleteStaleMagicLinks :execrows
+DELETE FROM magic_links
+WHERE expires_at < sqlc.arg(cutoff)::timestamp;
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs
+WHERE status IN ('done', 'failed')
+  AND finished_at < sqlc.arg(cutoff)::timestamp;
+
+-- name: DeleteDispatchedOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE dispatched_at < sqlc.arg(cutoff)::timestamp
+  AND NOT EXISTS (
+    SELECT 1 FROM webhook_deliveries
+    WHERE webhook_deliveries.event_id = outbox_events.id
+      AND webhook_deliveries.status = 'pending'
+  );
+
+-- name: DeleteExpiredMutedWords :execrows
+DELETE FROM muted_words
+WHERE expires_at < sqlc.arg(cutoff)::timestamp;
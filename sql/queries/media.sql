@@ -0,0 +1,102 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, updated_at, user_id, storage_key, content_type, size_bytes, alt_text)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT * FROM media
+WHERE id = $1;
+
+-- name: ListMediaByIDs :many
+SELECT * FROM media
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: UpdateMediaAltText :one
+UPDATE media SET alt_text = $3,
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: SetMediaStatus :one
+UPDATE media SET status = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CompleteMediaProcessing :one
+UPDATE media SET status = 'ready',
+storage_key = $2,
+content_type = $3,
+size_bytes = $4,
+width = $5,
+height = $6,
+blurhash = $7,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: FailMediaProcessing :exec
+UPDATE media SET status = 'failed',
+failure = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateMediaVariant :exec
+INSERT INTO media_variants (media_id, name, storage_key, content_type, width, height)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (media_id, name) DO UPDATE SET storage_key = EXCLUDED.storage_key,
+content_type = EXCLUDED.content_type,
+width = EXCLUDED.width,
+height = EXCLUDED.height;
+
+-- name: ListMediaVariants :many
+SELECT * FROM media_variants
+WHERE media_id = ANY(sqlc.arg(media_ids)::uuid[])
+ORDER BY media_id, name;
+
+-- name: AttachChirpMedia :execrows
+INSERT INTO chirp_media (chirp_id, media_id, position)
+SELECT sqlc.arg(chirp_id)::uuid, media.id, ids.position
+FROM unnest(sqlc.arg(media_ids)::uuid[]) WITH ORDINALITY AS ids(media_id, position)
+JOIN media ON media.id = ids.media_id
+WHERE media.user_id = sqlc.arg(user_id)::uuid
+  AND media.status = 'ready'
+ON CONFLICT DO NOTHING;
+
+-- name: ListMediaForChirps :many
+SELECT chirp_media.chirp_id, media.id, media.created_at, media.updated_at, media.user_id, media.storage_key, media.content_type, media.size_bytes, media.alt_text, media.status, media.width, media.height, media.blurhash, media.failure
+FROM chirp_media
+JOIN media ON media.id = chirp_media.media_id
+WHERE chirp_media.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_media.chirp_id, chirp_media.position;
+
+-- name: ListPurgeableMediaKeys :many
+SELECT media.storage_key FROM media
+WHERE media.id IN (
+    SELECT media.id FROM media
+    JOIN users ON users.id = media.user_id
+    WHERE users.deleted_at <= sqlc.arg(cutoff)::timestamp
+    UNION
+    SELECT chirp_media.media_id FROM chirp_media
+    JOIN chirps ON chirps.id = chirp_media.chirp_id
+    WHERE chirps.deleted_at <= sqlc.arg(cutoff)::timestamp
+)
+UNION ALL
+SELECT media_variants.storage_key FROM media_variants
+WHERE media_variants.media_id IN (
+    SELECT media.id FROM media
+    JOIN users ON users.id = media.user_id
+    WHERE users.deleted_at <= sqlc.arg(cutoff)::timestamp
+    UNION
+    SELECT chirp_media.media_id FROM chirp_media
+    JOIN chirps ON chirps.id = chirp_media.chirp_id
+    WHERE chirps.deleted_at <= sqlc.arg(cutoff)::timestamp
+);
+
+-- name: PurgeDeletedChirpMedia :execrows
+DELETE FROM media
+WHERE id IN (
+    SELECT chirp_media.media_id FROM chirp_media
+    JOIN chirps ON chirps.id = chirp_media.chirp_id
+    WHERE chirps.deleted_at <= sqlc.arg(cutoff)::timestamp
+);
@@ -0,0 +1,36 @@
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, updated_at, conversation_id, sender_id, body)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3)
+RETURNING *;
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE id = $1 AND conversation_id = $2;
+
+-- name: ListMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = messages.sender_id AND users.deleted_at IS NOT NULL
+  )
+  AND (
+    sqlc.narg(before_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(before_at)::timestamp, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_messages);
+
+-- name: UpdateMessage :one
+UPDATE messages SET body = $2,
+edited_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteMessage :one
+UPDATE messages SET body = '',
+deleted_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
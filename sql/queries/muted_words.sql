@@ -0,0 +1,21 @@
+-- name: CreateMutedWord :one
+INSERT INTO muted_words (id, created_at, user_id, phrase, action, expires_at)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4)
+ON CONFLICT (user_id, phrase)
+DO UPDATE SET action = EXCLUDED.action,
+expires_at = EXCLUDED.expires_at
+RETURNING *;
+
+-- name: ListMutedWords :many
+SELECT * FROM muted_words
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListActiveMutedWords :many
+SELECT * FROM muted_words
+WHERE user_id = $1
+  AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: DeleteMutedWord :execrows
+DELETE FROM muted_words
+WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,73 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, group_key, actor_id, actor_ids, chirp_id, data)
+SELECT gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, array_remove(ARRAY[$4::uuid], NULL), $5, $6
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.user_id = $1
+      AND notification_preferences.type = $2
+      AND NOT notification_preferences.enabled
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET actor_id = EXCLUDED.actor_id,
+actor_ids = CASE
+    WHEN EXCLUDED.actor_id IS NULL OR EXCLUDED.actor_id = ANY(notifications.actor_ids) THEN notifications.actor_ids
+    ELSE notifications.actor_ids || EXCLUDED.actor_id
+END,
+actor_count = CASE
+    WHEN EXCLUDED.actor_id IS NULL OR EXCLUDED.actor_id = ANY(notifications.actor_ids) THEN notifications.actor_count
+    ELSE notifications.actor_count + 1
+END,
+data = EXCLUDED.data,
+updated_at = NOW()
+RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  -- left out while the actor or chirp is deleted, they come back if it's
+  -- restored
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = notifications.actor_id AND users.deleted_at IS NOT NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id AND chirps.deleted_at IS NOT NULL
+  )
+  AND (
+    sqlc.narg(before_at)::timestamp IS NULL
+    OR (updated_at, id) < (sqlc.narg(before_at)::timestamp, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY updated_at DESC, id DESC
+LIMIT sqlc.arg(max_notifications);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = notifications.actor_id AND users.deleted_at IS NOT NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id AND chirps.deleted_at IS NOT NULL
+  );
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE id = $1 AND user_id = $2 AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, type)
+DO UPDATE SET enabled = EXCLUDED.enabled,
+updated_at = NOW();
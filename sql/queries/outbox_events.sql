@@ -0,0 +1,19 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (id, created_at, event_type, payload, audience_id, recipient_ids, subject_id)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetOutboxEvent :one
+SELECT * FROM outbox_events
+WHERE id = $1;
+
+-- name: ClaimOutboxEvents :many
+SELECT * FROM outbox_events
+WHERE dispatched_at IS NULL
+ORDER BY created_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events SET dispatched_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,28 @@
+-- name: PinChirp :execrows
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+SELECT sqlc.arg(user_id)::uuid, chirps.id, NOW() FROM chirps
+WHERE chirps.id = sqlc.arg(chirp_id)::uuid
+  AND chirps.user_id = sqlc.arg(user_id)::uuid
+  AND chirps.deleted_at IS NULL
+  AND (
+    SELECT COUNT(*) FROM pinned_chirps
+    JOIN chirps AS pinned ON pinned.id = pinned_chirps.chirp_id
+    WHERE pinned_chirps.user_id = sqlc.arg(user_id)::uuid
+      AND pinned.deleted_at IS NULL
+  ) < sqlc.arg(max_pinned)::int
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: IsChirpPinned :one
+SELECT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE user_id = $1 AND chirp_id = $2
+) AS pinned;
+
+-- name: ListPinnedChirpIDs :many
+SELECT chirp_id FROM pinned_chirps
+WHERE user_id = $1
+ORDER BY pinned_at DESC;
@@ -0,0 +1,55 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, created_at, multiple, closes_at)
+VALUES ($1, NOW(), $2, $3);
+
+-- name: CreatePollOptions :exec
+INSERT INTO poll_options (chirp_id, position, text)
+SELECT sqlc.arg(chirp_id)::uuid, options.ordinality - 1, options.text
+FROM unnest(sqlc.arg(texts)::text[]) WITH ORDINALITY AS options(text, ordinality);
+
+-- name: GetPoll :one
+SELECT polls.*,
+    (SELECT COUNT(*) FROM poll_options WHERE poll_options.chirp_id = polls.chirp_id)::int AS option_count
+FROM polls
+WHERE chirp_id = $1;
+
+-- name: ListPolls :many
+SELECT polls.*,
+    (SELECT COUNT(*) FROM poll_votes WHERE poll_votes.chirp_id = polls.chirp_id)::bigint AS voters
+FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListPollOptions :many
+SELECT poll_options.*,
+    (
+      SELECT COUNT(*) FROM poll_votes
+      WHERE poll_votes.chirp_id = poll_options.chirp_id
+        AND poll_options.position = ANY(poll_votes.choices)
+    )::bigint AS votes
+FROM poll_options
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: ListPollVotesByUser :many
+SELECT * FROM poll_votes
+WHERE user_id = sqlc.arg(user_id)
+  AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: VotePoll :execrows
+INSERT INTO poll_votes (chirp_id, user_id, choices, created_at)
+SELECT polls.chirp_id, sqlc.arg(user_id), sqlc.arg(choices)::int[], NOW() FROM polls
+WHERE polls.chirp_id = sqlc.arg(chirp_id)
+  AND polls.closes_at > NOW()
+ON CONFLICT DO NOTHING;
+
+-- name: ClosePoll :execrows
+UPDATE polls SET closed_at = NOW()
+WHERE chirp_id = $1 AND closed_at IS NULL;
+
+-- name: StorePollTallies :exec
+UPDATE poll_options SET final_votes = (
+    SELECT COUNT(*) FROM poll_votes
+    WHERE poll_votes.chirp_id = poll_options.chirp_id
+      AND poll_options.position = ANY(poll_votes.choices)
+)
+WHERE chirp_id = $1;
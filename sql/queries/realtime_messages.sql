@@ -0,0 +1,16 @@
+-- name: PublishRealtimeMessage :exec
+WITH message AS (
+    INSERT INTO realtime_messages (id, created_at, topic, type, data)
+    VALUES (gen_random_uuid(), NOW(), $1, $2, $3)
+    RETURNING id
+)
+SELECT pg_notify('realtime', message.id::text)
+FROM message;
+
+-- name: GetRealtimeMessage :one
+SELECT * FROM realtime_messages
+WHERE id = $1;
+
+-- name: DeleteOldRealtimeMessages :execrows
+DELETE FROM realtime_messages
+WHERE created_at < $1;
@@ -20,4 +20,11 @@ SELECT users.* FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1
   AND revoked_at IS NULL
-  AND expires_at > NOW();
+  AND expires_at > NOW()
+  AND users.deleted_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
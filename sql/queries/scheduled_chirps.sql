@@ -0,0 +1,41 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, reply_to_id, visibility, mentions, media_ids, publish_at, status, poll)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: LockScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC NULLS LAST, created_at DESC;
+
+-- name: UpdateScheduledChirp :one
+UPDATE scheduled_chirps SET body = $3,
+visibility = $4,
+mentions = $5,
+media_ids = $6,
+publish_at = $7,
+status = $8,
+poll = $9,
+failure = '',
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: FailScheduledChirp :exec
+UPDATE scheduled_chirps SET status = 'failed',
+failure = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
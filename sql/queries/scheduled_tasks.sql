@@ -0,0 +1,14 @@
+-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock(sqlc.arg(key)::bigint) AS locked;
+
+-- name: AdvisoryUnlock :one
+SELECT pg_advisory_unlock(sqlc.arg(key)::bigint) AS unlocked;
+
+-- name: GetScheduledTask :one
+SELECT * FROM scheduled_tasks
+WHERE name = $1;
+
+-- name: MarkScheduledTaskRun :exec
+INSERT INTO scheduled_tasks (name, last_run_at)
+VALUES ($1, NOW())
+ON CONFLICT (name) DO UPDATE SET last_run_at = NOW();
@@ -0,0 +1,45 @@
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, period_start, period_end, webhook_event_id)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetActiveSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+  AND status IN ('active', 'past_due')
+  AND period_start <= NOW()
+  AND period_end > NOW()
+ORDER BY period_end DESC
+LIMIT 1;
+
+-- name: GetLatestSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+ORDER BY period_end DESC
+LIMIT 1;
+
+-- name: UpdateSubscriptionStatus :one
+UPDATE subscriptions SET status = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateOpenSubscriptions :execrows
+UPDATE subscriptions SET status = $2,
+updated_at = NOW()
+WHERE user_id = $1
+  AND status IN ('active', 'past_due')
+  AND period_end > NOW();
+
+-- name: EndOpenSubscriptions :execrows
+UPDATE subscriptions SET status = $2,
+period_end = GREATEST(period_start, LEAST(period_end, NOW())),
+updated_at = NOW()
+WHERE user_id = $1
+  AND status IN ('active', 'past_due')
+  AND period_end > NOW();
+
+-- name: ListSubscriptionsByUser :many
+SELECT * FROM subscriptions
+WHERE user_id = $1
+ORDER BY period_start DESC;
@@ -0,0 +1,27 @@
+-- name: SearchUsers :many
+SELECT users.id, users.handle, users.display_name, users.avatar_media_id, users.is_private,
+    COALESCE(user_stats.follower_count, 0)::bigint AS follower_count
+FROM users
+LEFT JOIN user_stats ON user_stats.user_id = users.id
+WHERE users.handle IS NOT NULL
+  AND users.deleted_at IS NULL
+  AND (
+    LOWER(users.handle) LIKE sqlc.arg(prefix)::text
+    OR LOWER(users.display_name) LIKE sqlc.arg(prefix)::text
+    OR LOWER(users.handle) % sqlc.arg(query)::text
+    OR sqlc.arg(query)::text <% LOWER(users.display_name)
+  )
+  AND (
+    sqlc.narg(viewer_id)::uuid IS NULL
+    OR NOT EXISTS (
+      SELECT 1 FROM user_blocks
+      WHERE (user_blocks.blocker_id = sqlc.narg(viewer_id)::uuid AND user_blocks.blocked_id = users.id)
+         OR (user_blocks.blocker_id = users.id AND user_blocks.blocked_id = sqlc.narg(viewer_id)::uuid)
+    )
+  )
+ORDER BY
+  (LOWER(users.handle) = sqlc.arg(query)::text OR LOWER(users.display_name) = sqlc.arg(query)::text) DESC,
+  follower_count DESC,
+  GREATEST(similarity(LOWER(users.handle), sqlc.arg(query)::text), word_similarity(sqlc.arg(query)::text, LOWER(users.display_name))) DESC,
+  users.id
+LIMIT sqlc.arg(max_users);
@@ -0,0 +1,9 @@
+-- name: ReconcileFollowerCounts :execrows
+INSERT INTO user_stats (user_id, follower_count)
+SELECT users.id, COUNT(follows.follower_id)
+FROM users
+LEFT JOIN follows ON follows.followee_id = users.id
+GROUP BY users.id
+ON CONFLICT (user_id) DO UPDATE
+SET follower_count = EXCLUDED.follower_count
+WHERE user_stats.follower_count <> EXCLUDED.follower_count;
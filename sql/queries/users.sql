@@ -5,14 +5,74 @@ RETURNING *;
 
 -- name: GetUserByLogin :one
 SELECT * FROM users
-WHERE email = $1 LIMIT 1;
+WHERE email = $1
+  AND deleted_at IS NULL
+LIMIT 1;
 
 -- name: UpdateUser :one
-UPDATE users SET email = $1, hashed_password = $2
-WHERE id = $3
+UPDATE users SET email = COALESCE(sqlc.narg(email), email),
+hashed_password = COALESCE(sqlc.narg(hashed_password), hashed_password),
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
 RETURNING *;
 
--- name: UpdateUserRed :one
-UPDATE users SET is_chirpy_red = TRUE
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: LockUser :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: SetUserPrivate :one
+UPDATE users SET is_private = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE LOWER(handle) = LOWER($1)
+  AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: UpdateUserProfile :one
+UPDATE users SET handle = $2,
+display_name = $3,
+bio = $4,
+avatar_media_id = $5,
+website = $6,
+updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: ListUserIDsByHandles :many
+SELECT id FROM users
+WHERE LOWER(handle) = ANY(sqlc.arg(handles)::text[])
+  AND deleted_at IS NULL;
+
+-- name: DeleteUser :execrows
+UPDATE users SET deleted_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: GetDeletedUserByLogin :one
+SELECT * FROM users
+WHERE email = $1
+  AND deleted_at IS NOT NULL
+LIMIT 1;
+
+-- name: RestoreUser :one
+UPDATE users SET deleted_at = NULL,
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND deleted_at > sqlc.arg(cutoff)::timestamp
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at <= sqlc.arg(cutoff)::timestamp;
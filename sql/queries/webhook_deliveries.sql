@@ -0,0 +1,64 @@
+-- name: CreateWebhookDeliveries :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_id, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_subscriptions.id, sqlc.arg(event_id)::uuid, NOW()
+FROM webhook_subscriptions
+WHERE webhook_subscriptions.active
+  AND sqlc.arg(event_type)::text = ANY(webhook_subscriptions.events)
+  AND (
+    sqlc.narg(audience_id)::uuid IS NULL
+    OR webhook_subscriptions.user_id = sqlc.narg(audience_id)::uuid
+    OR EXISTS (
+      SELECT 1 FROM follows
+      WHERE follows.follower_id = webhook_subscriptions.user_id
+        AND follows.followee_id = sqlc.narg(audience_id)::uuid
+    )
+  )
+  AND (
+    sqlc.narg(recipient_ids)::uuid[] IS NULL
+    OR webhook_subscriptions.user_id = ANY(sqlc.narg(recipient_ids)::uuid[])
+  )
+  AND (
+    sqlc.narg(subject_id)::uuid IS NULL
+    OR NOT EXISTS (
+      SELECT 1 FROM user_blocks
+      WHERE (user_blocks.blocker_id = webhook_subscriptions.user_id AND user_blocks.blocked_id = sqlc.narg(subject_id)::uuid)
+         OR (user_blocks.blocker_id = sqlc.narg(subject_id)::uuid AND user_blocks.blocked_id = webhook_subscriptions.user_id)
+    )
+  );
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = NOW() + INTERVAL '5 minutes',
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries SET status = 'delivered',
+attempts = attempts + 1,
+last_status_code = $2,
+last_error = NULL,
+delivered_at = NOW(),
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries SET status = $2,
+attempts = attempts + 1,
+next_attempt_at = $3,
+last_status_code = $4,
+last_error = $5,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListWebhookDeliveriesBySubscription :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
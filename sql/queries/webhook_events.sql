@@ -0,0 +1,31 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, provider, event_id, event_type, payload)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+ON CONFLICT (provider, event_id) DO UPDATE SET status = 'pending', updated_at = NOW()
+-- only failed deliveries and claims abandoned mid-run are taken again
+WHERE webhook_events.status = 'failed'
+    OR (webhook_events.status = 'pending' AND webhook_events.updated_at < NOW() - INTERVAL '5 minutes')
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE sqlc.arg(status)::text = '' OR status = sqlc.arg(status)::text
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events SET status = $2,
+error = $3,
+attempts = attempts + 1,
+processed_at = NOW(),
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events SET status = 'pending', updated_at = NOW()
+WHERE id = $1 AND status = 'failed'
+RETURNING *;
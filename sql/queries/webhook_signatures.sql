@@ -0,0 +1,8 @@
+-- name: RecordWebhookSignature :execrows
+INSERT INTO webhook_signatures (provider, replay_key, created_at, expires_at)
+VALUES ($1, $2, NOW(), $3)
+ON CONFLICT (provider, replay_key) DO NOTHING;
+
+-- name: DeleteExpiredWebhookSignatures :execrows
+DELETE FROM webhook_signatures
+WHERE expires_at <= $1;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE magic_links (
+    token_hash TEXT PRIMARY KEY,
+    created_at TIMESTAMP,
+    user_id UUID NOT NULL,
+    nonce_hash TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE magic_links;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP,
+    CONSTRAINT uq_webhook_event
+        UNIQUE(provider, event_id)
+);
+
+CREATE INDEX idx_webhook_events_status ON webhook_events(status, created_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
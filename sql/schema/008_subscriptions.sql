@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    user_id UUID NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    webhook_event_id UUID,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_webhook_event
+        FOREIGN KEY(webhook_event_id)
+            REFERENCES webhook_events(id)
+            ON DELETE SET NULL
+);
+
+CREATE INDEX idx_subscriptions_user ON subscriptions(user_id, period_end);
+
+-- upgrades recorded before subscriptions existed never expire
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, period_start, period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', NOW(), '9999-12-31'
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users
+    DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+    ADD is_chirpy_red BOOL DEFAULT FALSE;
+
+UPDATE users SET is_chirpy_red = TRUE
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status IN ('active', 'past_due')
+      AND period_start <= NOW()
+      AND period_end > NOW()
+);
+
+DROP TABLE subscriptions;
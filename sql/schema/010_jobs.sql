@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 10,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_due ON jobs(kind, run_at)
+    WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE jobs;
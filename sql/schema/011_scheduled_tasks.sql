@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE scheduled_tasks (
+    name TEXT PRIMARY KEY,
+    last_run_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE scheduled_tasks;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at TIMESTAMP,
+    PRIMARY KEY(follower_id, followee_id),
+    CONSTRAINT fk_follower
+        FOREIGN KEY(follower_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_followee
+        FOREIGN KEY(followee_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_follows_followee ON follows(followee_id);
+
+-- +goose Down
+DROP TABLE follows;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD reply_to_id UUID DEFAULT NULL
+        REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_chirps_reply_to ON chirps(reply_to_id);
+
+CREATE TABLE chirp_likes (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP,
+    PRIMARY KEY(chirp_id, user_id),
+    CONSTRAINT fk_chirp
+        FOREIGN KEY(chirp_id)
+            REFERENCES chirps(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_likes;
+ALTER TABLE chirps
+    DROP COLUMN reply_to_id;
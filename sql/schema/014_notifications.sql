@@ -0,0 +1,47 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    group_key TEXT NOT NULL,
+    actor_id UUID DEFAULT NULL,
+    actor_count INT NOT NULL DEFAULT 1,
+    chirp_id UUID DEFAULT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    read_at TIMESTAMP DEFAULT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_actor
+        FOREIGN KEY(actor_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_chirp
+        FOREIGN KEY(chirp_id)
+            REFERENCES chirps(id)
+            ON DELETE CASCADE
+);
+
+-- repeated events are folded into the one unread entry of their group
+CREATE UNIQUE INDEX idx_notifications_unread_group
+    ON notifications(user_id, group_key) WHERE read_at IS NULL;
+CREATE INDEX idx_notifications_user ON notifications(user_id, updated_at DESC, id DESC);
+
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP,
+    PRIMARY KEY(user_id, type),
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,53 @@
+-- +goose Up
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL,
+    is_group BOOLEAN NOT NULL,
+    -- sorted participant ids of a 1:1 thread so each pair has only one
+    direct_key TEXT UNIQUE
+);
+
+CREATE TABLE conversation_participants (
+    conversation_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    joined_at TIMESTAMP,
+    last_read_at TIMESTAMP DEFAULT NULL,
+    PRIMARY KEY(conversation_id, user_id),
+    CONSTRAINT fk_conversation
+        FOREIGN KEY(conversation_id)
+            REFERENCES conversations(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_participants_user ON conversation_participants(user_id);
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP,
+    conversation_id UUID NOT NULL,
+    sender_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    edited_at TIMESTAMP DEFAULT NULL,
+    deleted_at TIMESTAMP DEFAULT NULL,
+    CONSTRAINT fk_conversation
+        FOREIGN KEY(conversation_id)
+            REFERENCES conversations(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_sender
+        FOREIGN KEY(sender_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_messages_conversation ON messages(conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE muted_words (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    user_id UUID NOT NULL,
+    phrase TEXT NOT NULL,
+    action TEXT NOT NULL,
+    expires_at TIMESTAMP DEFAULT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_muted_words_user_phrase ON muted_words(user_id, phrase);
+
+-- +goose Down
+DROP TABLE muted_words;
@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE users
+    ADD is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE follow_requests (
+    requester_id UUID NOT NULL,
+    target_id UUID NOT NULL,
+    created_at TIMESTAMP,
+    PRIMARY KEY(requester_id, target_id),
+    CONSTRAINT fk_requester
+        FOREIGN KEY(requester_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_target
+        FOREIGN KEY(target_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_follow_requests_target ON follow_requests(target_id, created_at);
+
+-- events about a private account's content only reach webhooks owned by
+-- the account or its followers
+ALTER TABLE outbox_events
+    ADD audience_id UUID DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE outbox_events
+    DROP COLUMN audience_id;
+DROP TABLE follow_requests;
+ALTER TABLE users
+    DROP COLUMN is_private;
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD visibility TEXT NOT NULL DEFAULT 'public'
+        CHECK (visibility IN ('public', 'unlisted', 'followers', 'mentioned'));
+
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    PRIMARY KEY(chirp_id, user_id),
+    CONSTRAINT fk_chirp
+        FOREIGN KEY(chirp_id)
+            REFERENCES chirps(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chirp_mentions_user ON chirp_mentions(user_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+ALTER TABLE chirps
+    DROP COLUMN visibility;
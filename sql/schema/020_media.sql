@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE media (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    user_id UUID NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_media_user ON media(user_id);
+
+-- a media item is attached to at most one chirp
+CREATE TABLE chirp_media (
+    chirp_id UUID NOT NULL,
+    media_id UUID NOT NULL UNIQUE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY(chirp_id, media_id),
+    CONSTRAINT fk_chirp
+        FOREIGN KEY(chirp_id)
+            REFERENCES chirps(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_media
+        FOREIGN KEY(media_id)
+            REFERENCES media(id)
+            ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_media;
+DROP TABLE media;
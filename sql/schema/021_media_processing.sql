@@ -0,0 +1,35 @@
+-- +goose Up
+-- media uploaded before processing existed is already usable
+ALTER TABLE media
+    ADD status TEXT NOT NULL DEFAULT 'ready'
+        CHECK (status IN ('uploading', 'processing', 'ready', 'failed')),
+    ADD width INTEGER NOT NULL DEFAULT 0,
+    ADD height INTEGER NOT NULL DEFAULT 0,
+    ADD blurhash TEXT NOT NULL DEFAULT '',
+    ADD failure TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE media
+    ALTER COLUMN status SET DEFAULT 'uploading';
+
+CREATE TABLE media_variants (
+    media_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    PRIMARY KEY(media_id, name),
+    CONSTRAINT fk_media
+        FOREIGN KEY(media_id)
+            REFERENCES media(id)
+            ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE media_variants;
+ALTER TABLE media
+    DROP COLUMN failure,
+    DROP COLUMN blurhash,
+    DROP COLUMN height,
+    DROP COLUMN width,
+    DROP COLUMN status;
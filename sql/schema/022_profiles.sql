@@ -0,0 +1,37 @@
+-- +goose Up
+ALTER TABLE users
+    ADD handle TEXT DEFAULT NULL,
+    ADD display_name TEXT NOT NULL DEFAULT '',
+    ADD bio TEXT NOT NULL DEFAULT '',
+    ADD avatar_media_id UUID DEFAULT NULL
+        REFERENCES media(id) ON DELETE SET NULL,
+    ADD website TEXT NOT NULL DEFAULT '';
+
+-- handles are unique regardless of case but keep the case they were
+-- chosen with
+CREATE UNIQUE INDEX idx_users_handle ON users (LOWER(handle));
+
+-- a handle someone moved away from keeps pointing at them for a while
+-- and can't be claimed by anyone else until it expires
+CREATE TABLE handle_redirects (
+    old_handle TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_handle_redirects_handle ON handle_redirects (LOWER(old_handle));
+
+-- +goose Down
+DROP TABLE handle_redirects;
+DROP INDEX idx_users_handle;
+ALTER TABLE users
+    DROP COLUMN website,
+    DROP COLUMN avatar_media_id,
+    DROP COLUMN bio,
+    DROP COLUMN display_name,
+    DROP COLUMN handle;
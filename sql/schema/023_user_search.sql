@@ -0,0 +1,56 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- prefix matches on short handles are cheaper on a plain btree, trigrams
+-- cover the fuzzy part
+CREATE INDEX idx_users_handle_prefix ON users (LOWER(handle) text_pattern_ops);
+CREATE INDEX idx_users_handle_trgm ON users USING GIN (LOWER(handle) gin_trgm_ops);
+CREATE INDEX idx_users_display_name_trgm ON users USING GIN (LOWER(display_name) gin_trgm_ops);
+
+-- counts that are too slow to compute per search result, kept up to date
+-- by triggers and reconciled periodically
+CREATE TABLE user_stats (
+    user_id UUID PRIMARY KEY,
+    follower_count BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_stats_follower_count ON user_stats(follower_count DESC);
+
+INSERT INTO user_stats (user_id, follower_count)
+SELECT users.id, COUNT(follows.follower_id)
+FROM users
+LEFT JOIN follows ON follows.followee_id = users.id
+GROUP BY users.id;
+
+-- +goose StatementBegin
+CREATE FUNCTION update_follower_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO user_stats (user_id, follower_count)
+        VALUES (NEW.followee_id, 1)
+        ON CONFLICT (user_id) DO UPDATE
+        SET follower_count = user_stats.follower_count + 1;
+    ELSE
+        UPDATE user_stats SET follower_count = GREATEST(follower_count - 1, 0)
+        WHERE user_id = OLD.followee_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_follower_count
+    AFTER INSERT OR DELETE ON follows
+    FOR EACH ROW EXECUTE FUNCTION update_follower_count();
+
+-- +goose Down
+DROP TRIGGER follows_follower_count ON follows;
+DROP FUNCTION update_follower_count();
+DROP TABLE user_stats;
+DROP INDEX idx_users_display_name_trgm;
+DROP INDEX idx_users_handle_trgm;
+DROP INDEX idx_users_handle_prefix;
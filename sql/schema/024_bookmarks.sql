@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE bookmark_folders (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_bookmark_folders_name ON bookmark_folders (user_id, LOWER(name));
+
+-- bookmarks are kept when the chirp stops being visible to their owner,
+-- they're only listed as unavailable
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    folder_id UUID DEFAULT NULL
+        REFERENCES bookmark_folders(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, chirp_id),
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_chirp
+        FOREIGN KEY(chirp_id)
+            REFERENCES chirps(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_bookmarks_user_created ON bookmarks(user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX idx_bookmarks_folder ON bookmarks(folder_id);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_folders;
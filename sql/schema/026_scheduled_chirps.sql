@@ -0,0 +1,27 @@
+-- +goose Up
+-- drafts and chirps waiting for publish_at, published ones become a row
+-- in chirps and are removed from here
+CREATE TABLE scheduled_chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    user_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    reply_to_id UUID DEFAULT NULL,
+    visibility TEXT NOT NULL,
+    mentions UUID[] NOT NULL DEFAULT '{}',
+    media_ids UUID[] NOT NULL DEFAULT '{}',
+    publish_at TIMESTAMP DEFAULT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('draft', 'scheduled', 'failed')),
+    failure TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_scheduled_chirps_user ON scheduled_chirps(user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
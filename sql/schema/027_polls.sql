@@ -0,0 +1,53 @@
+-- +goose Up
+CREATE TABLE polls (
+    chirp_id UUID PRIMARY KEY,
+    created_at TIMESTAMP,
+    multiple BOOLEAN NOT NULL,
+    closes_at TIMESTAMP NOT NULL,
+    -- set once the final tallies are stored
+    closed_at TIMESTAMP DEFAULT NULL,
+    CONSTRAINT fk_chirp
+        FOREIGN KEY(chirp_id)
+            REFERENCES chirps(id)
+            ON DELETE CASCADE
+);
+
+CREATE TABLE poll_options (
+    chirp_id UUID NOT NULL,
+    position INT NOT NULL,
+    text TEXT NOT NULL,
+    final_votes BIGINT DEFAULT NULL,
+    PRIMARY KEY(chirp_id, position),
+    CONSTRAINT fk_poll
+        FOREIGN KEY(chirp_id)
+            REFERENCES polls(chirp_id)
+            ON DELETE CASCADE
+);
+
+-- one ballot per user, holding every option they picked
+CREATE TABLE poll_votes (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    choices INT[] NOT NULL,
+    created_at TIMESTAMP,
+    PRIMARY KEY(chirp_id, user_id),
+    CONSTRAINT fk_poll
+        FOREIGN KEY(chirp_id)
+            REFERENCES polls(chirp_id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(id)
+            ON DELETE CASCADE
+);
+
+-- null when the scheduled chirp has no poll
+ALTER TABLE scheduled_chirps
+    ADD poll JSONB NOT NULL DEFAULT 'null';
+
+-- +goose Down
+ALTER TABLE scheduled_chirps
+    DROP COLUMN poll;
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
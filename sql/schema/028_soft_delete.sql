@@ -0,0 +1,18 @@
+-- +goose Up
+-- deleted chirps and accounts are kept for a restore window before the
+-- purge task removes them for good
+ALTER TABLE chirps
+    ADD deleted_at TIMESTAMP DEFAULT NULL;
+ALTER TABLE users
+    ADD deleted_at TIMESTAMP DEFAULT NULL;
+
+CREATE INDEX idx_chirps_deleted ON chirps(user_id, deleted_at DESC, id DESC) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_users_deleted ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_users_deleted;
+DROP INDEX idx_chirps_deleted;
+ALTER TABLE users
+    DROP COLUMN deleted_at;
+ALTER TABLE chirps
+    DROP COLUMN deleted_at;
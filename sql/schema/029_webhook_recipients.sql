@@ -0,0 +1,11 @@
+-- +goose Up
+-- events that only concern specific accounts go to their webhooks alone,
+-- and accounts blocked either way with the subject never get an event
+ALTER TABLE outbox_events
+    ADD recipient_ids UUID[] DEFAULT NULL,
+    ADD subject_id UUID DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE outbox_events
+    DROP COLUMN subject_id,
+    DROP COLUMN recipient_ids;
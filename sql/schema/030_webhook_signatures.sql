@@ -0,0 +1,15 @@
+-- +goose Up
+-- signed deliveries seen inside the tolerance window, shared by every
+-- replica so a captured request can't be replayed against another one
+CREATE TABLE webhook_signatures (
+    provider TEXT NOT NULL,
+    replay_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (provider, replay_key)
+);
+
+CREATE INDEX idx_webhook_signatures_expires ON webhook_signatures(expires_at);
+
+-- +goose Down
+DROP TABLE webhook_signatures;
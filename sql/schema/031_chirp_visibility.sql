@@ -0,0 +1,60 @@
+-- +goose Up
+-- whether viewer_id (NULL when logged out) is in chirp's audience: nobody
+-- blocked anybody and its visibility lets them. Deleted chirps still have
+-- one, so the stream can tell it who to send their deletion to.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_readable_by(chirp chirps, viewer_id UUID) RETURNS BOOLEAN AS $$
+    SELECT (
+        viewer_id IS NULL
+        OR NOT EXISTS (
+          SELECT 1 FROM user_blocks
+          WHERE (user_blocks.blocker_id = viewer_id AND user_blocks.blocked_id = chirp.user_id)
+             OR (user_blocks.blocker_id = chirp.user_id AND user_blocks.blocked_id = viewer_id)
+        )
+      )
+      AND (
+        chirp.user_id = viewer_id
+        OR (
+          chirp.visibility IN ('public', 'unlisted')
+          AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirp.user_id AND users.is_private
+          )
+        )
+        OR (
+          chirp.visibility <> 'mentioned'
+          AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = viewer_id
+              AND follows.followee_id = chirp.user_id
+          )
+        )
+        OR (
+          chirp.visibility = 'mentioned'
+          AND EXISTS (
+            SELECT 1 FROM chirp_mentions
+            WHERE chirp_mentions.chirp_id = chirp.id
+              AND chirp_mentions.user_id = viewer_id
+          )
+        )
+      )
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- whether viewer_id can see chirp: it and its author aren't deleted and
+-- they are in its audience. Every query that shows chirps to someone goes
+-- through this.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(chirp chirps, viewer_id UUID) RETURNS BOOLEAN AS $$
+    SELECT chirp.deleted_at IS NULL
+      AND NOT EXISTS (
+        SELECT 1 FROM users
+        WHERE users.id = chirp.user_id AND users.deleted_at IS NOT NULL
+      )
+      AND chirp_readable_by(chirp, viewer_id)
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible_to(chirps, UUID);
+DROP FUNCTION chirp_readable_by(chirps, UUID);
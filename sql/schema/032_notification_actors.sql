@@ -0,0 +1,10 @@
+-- +goose Up
+-- who is folded into an unread notification, so a repeat by the same
+-- actor doesn't count them twice
+ALTER TABLE notifications ADD COLUMN actor_ids UUID[] NOT NULL DEFAULT '{}';
+
+UPDATE notifications SET actor_ids = ARRAY[actor_id]
+WHERE actor_id IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE notifications DROP COLUMN actor_ids;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- ids of stream events, resumed streams pick up after the last one they saw
+CREATE SEQUENCE IF NOT EXISTS chirp_event_seq;
+
+-- +goose Down
+DROP SEQUENCE IF EXISTS chirp_event_seq;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- payloads of realtime messages, NOTIFY only carries the id because
+-- Postgres caps it at 8000 bytes
+CREATE TABLE realtime_messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    topic TEXT NOT NULL,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL
+);
+
+CREATE INDEX idx_realtime_messages_created ON realtime_messages(created_at);
+
+-- +goose Down
+DROP TABLE realtime_messages;
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Weso1ek/chirpy/internal/database"
+	chirpfilters "github.com/Weso1ek/chirpy/internal/filters"
+	"github.com/Weso1ek/chirpy/internal/stream"
+	"github.com/Weso1ek/chirpy/internal/visibility"
+	"github.com/Weso1ek/chirpy/internal/webhooks"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const streamHeartbeat = 25 * time.Second
+
+// chirpWebhookEvents maps the stream events that integrators can also
+// subscribe to
+var chirpWebhookEvents = map[string]string{
+	stream.EventChirpCreated: webhooks.EventChirpCreated,
+	stream.EventChirpDeleted: webhooks.EventChirpDeleted,
+}
+
+// publishChirpEvent raises a stream event and the matching webhook, both
+// delivered once q's transaction commits. Chirps that aren't for everyone
+// or are unlisted are kept to the author and their followers;
+// mentioned-only ones skip webhooks entirely. Webhooks of accounts blocked
+// either way never get them. Event ids are handed out under a lock held
+// until commit, so call it last in the transaction.
+func publishChirpEvent(ctx context.Context, q *database.Queries, eventType string, chirp Chirp) error {
+	author, err := q.GetUser(ctx, chirp.UserId)
+	if err != nil {
+		return err
+	}
+
+	webhookEvent, ok := chirpWebhookEvents[eventType]
+	if ok && chirp.Visibility != visibility.Mentioned {
+		opts := []webhooks.Option{webhooks.Subject(author.ID)}
+		// a chirp.created subscription is a listing too
+		if author.IsPrivate || chirp.Visibility == visibility.Followers || !visibility.Listed(chirp.Visibility) {
+			opts = append(opts, webhooks.Audience(author.ID))
+		}
+		err = webhooks.Publish(ctx, q, webhookEvent, chirp, opts...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return q.NotifyChirpEvent(ctx, database.NotifyChirpEventParams{
+		EventType: eventType,
+		ChirpID:   chirp.ID,
+	})
+}
+
+// loadStreamEvent builds the stream event for a notice. Deleted chirps are
+// still loaded so subscribers can tell whose chirp went away.
+func (cfg *apiConfig) loadStreamEvent(ctx context.Context, notice stream.Notice) (stream.Event, error) {
+	chirp, err := cfg.dbQueries.GetChirpWithDeleted(ctx, notice.ChirpID)
+	if err != nil {
+		return stream.Event{}, err
+	}
+
+	// everyone gets the same payload, so nothing viewer specific
+	details := []Chirp{chirpFromDB(chirp)}
+	err = cfg.loadChirpDetails(ctx, cfg.dbQueries, uuid.NullUUID{}, details)
+	if err != nil {
+		return stream.Event{}, err
+	}
+	dat, err := json.Marshal(details[0])
+	if err != nil {
+		return stream.Event{}, err
+	}
+	return stream.NewEvent(notice.ID, notice.Type, dat)
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Chirp)
+}
+
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming unsupported", nil)
+		return
+	}
+
+	var filters []func(stream.Event) bool
+
+	if authorId := r.URL.Query().Get("author_id"); authorId != "" {
+		authorUUID, err := uuid.Parse(authorId)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author id", err)
+			return
+		}
+		filters = append(filters, func(e stream.Event) bool {
+			return e.Author() == authorUUID
+		})
+	}
+
+	if hashtag := r.URL.Query().Get("hashtag"); hashtag != "" {
+		filters = append(filters, func(e stream.Event) bool {
+			return e.HasHashtag(hashtag)
+		})
+	}
+
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// like the chirp list, unlisted chirps only show up when following
+	// one author or on the viewer's own timeline
+	listing := r.URL.Query().Get("author_id") == "" && r.URL.Query().Get("timeline") != "true"
+	if listing {
+		filters = append(filters, func(e stream.Event) bool {
+			return visibility.Listed(e.Visibility()) || (viewer.Valid && e.Author() == viewer.UUID)
+		})
+	}
+
+	if viewer.Valid {
+		// only hidden chirps are dropped, collapsing is up to the client
+		mutedWords, err := mutedWordFilters(r.Context(), cfg.dbQueries, viewer.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load stream", err)
+			return
+		}
+		if len(mutedWords) > 0 {
+			filters = append(filters, func(e stream.Event) bool {
+				return e.Author() == viewer.UUID || !chirpfilters.Match(mutedWords, e.Body(), time.Now()).Hidden()
+			})
+		}
+	}
+
+	if r.URL.Query().Get("timeline") == "true" {
+		if !viewer.Valid {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", nil)
+			return
+		}
+
+		// muted and blocked accounts are already left out by the query
+		authors, err := cfg.dbQueries.ListTimelineAuthorIDs(r.Context(), viewer.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load timeline", err)
+			return
+		}
+		timeline := map[uuid.UUID]bool{viewer.UUID: true}
+		for _, id := range authors {
+			timeline[id] = true
+		}
+		filters = append(filters, func(e stream.Event) bool {
+			return timeline[e.Author()]
+		})
+	}
+
+	filter := func(e stream.Event) bool {
+		for _, f := range filters {
+			if !f(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// who may see a chirp is only decided by chirp_readable_by, per event so
+	// a block, an unfollow or a switch to private applies right away
+	readable := func(e stream.Event) bool {
+		ok, err := cfg.dbQueries.ChirpReadableBy(r.Context(), database.ChirpReadableByParams{
+			ViewerID: viewer,
+			ID:       e.ChirpID(),
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Couldn't check stream event %d: %s", e.ID, err)
+		}
+		return err == nil && ok
+	}
+
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	sub, missed, complete := cfg.stream.Subscribe(lastID, filter)
+	defer cfg.stream.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// tell the client it has to refetch because the gap can't be replayed
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if readable(event) {
+			writeStreamEvent(w, event)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.C:
+			// the broker dropped us for falling behind, the client reconnects
+			// with Last-Event-ID and resumes from the buffer
+			if !ok {
+				return
+			}
+			if !readable(event) {
+				continue
+			}
+			writeStreamEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/entitlements"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	planFree = "free"
+	planRed  = "red"
+
+	subscriptionStatusActive   = "active"
+	subscriptionStatusPastDue  = "past_due"
+	subscriptionStatusCanceled = "canceled"
+	subscriptionStatusRefunded = "refunded"
+
+	subscriptionPeriod = 30 * 24 * time.Hour
+)
+
+type Subscription struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Plan        string    `json:"plan"`
+	Status      string    `json:"status"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+// activePlan returns the plan of the subscription covering now, Red
+// therefore lapses on its own once the period ends
+func (cfg *apiConfig) activePlan(ctx context.Context, userID uuid.UUID) (string, error) {
+	subscription, err := cfg.dbQueries.GetActiveSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return planFree, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return subscription.Plan, nil
+}
+
+// limitsFor returns what userID is entitled to on their current plan
+func (cfg *apiConfig) limitsFor(ctx context.Context, userID uuid.UUID) (entitlements.Limits, error) {
+	plan, err := cfg.activePlan(ctx, userID)
+	if err != nil {
+		return entitlements.Limits{}, err
+	}
+	return cfg.entitlements.For(plan), nil
+}
+
+// userFromDB builds the User response, deriving Red from the active plan
+func (cfg *apiConfig) userFromDB(ctx context.Context, user database.User) (User, error) {
+	plan, err := cfg.activePlan(ctx, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+
+	resp := User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt.Time,
+		UpdatedAt:   user.UpdatedAt.Time,
+		Email:       user.Email.String,
+		IsChirpyRed: plan != planFree,
+		IsPrivate:   user.IsPrivate,
+		Handle:      user.Handle.String,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Website:     user.Website,
+	}
+	if user.AvatarMediaID.Valid {
+		resp.AvatarMediaID = &user.AvatarMediaID.UUID
+	}
+	return resp, nil
+}
+
+// startSubscriptionPeriod opens a new period, right after the current one
+// when renewing early so paid time is never lost
+func (cfg *apiConfig) startSubscriptionPeriod(ctx context.Context, q *database.Queries, userID uuid.UUID, plan string, eventID uuid.UUID) error {
+	start := time.Now().UTC()
+
+	current, err := q.GetActiveSubscription(ctx, userID)
+	if err == nil && current.PeriodEnd.After(start) {
+		start = current.PeriodEnd
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, err = q.CreateSubscription(ctx, database.CreateSubscriptionParams{
+		UserID:         userID,
+		Plan:           plan,
+		Status:         subscriptionStatusActive,
+		PeriodStart:    start,
+		PeriodEnd:      start.Add(subscriptionPeriod),
+		WebhookEventID: uuid.NullUUID{UUID: eventID, Valid: true},
+	})
+	return err
+}
+
+func (cfg *apiConfig) handlerSubscriptions(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	subscriptions, err := cfg.dbQueries.ListSubscriptionsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list subscriptions", err)
+		return
+	}
+
+	subscriptionsResp := []Subscription{}
+	for _, s := range subscriptions {
+		subscriptionsResp = append(subscriptionsResp, Subscription{
+			ID:          s.ID,
+			CreatedAt:   s.CreatedAt.Time,
+			UpdatedAt:   s.UpdatedAt.Time,
+			Plan:        s.Plan,
+			Status:      s.Status,
+			PeriodStart: s.PeriodStart,
+			PeriodEnd:   s.PeriodEnd,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, subscriptionsResp)
+}
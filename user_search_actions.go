@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/handles"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const maxUserSearchQuery = 50
+
+// likeEscaper keeps LIKE wildcards in a search query literal, handles
+// themselves contain underscores
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type UserSummary struct {
+	ID            uuid.UUID `json:"id"`
+	Handle        string    `json:"handle"`
+	DisplayName   string    `json:"display_name"`
+	Avatar        *Media    `json:"avatar"`
+	IsPrivate     bool      `json:"is_private"`
+	FollowerCount int64     `json:"follower_count"`
+}
+
+// avatars loads the given avatar media keyed by id
+func (cfg *apiConfig) avatars(ctx context.Context, q *database.Queries, ids ...uuid.UUID) (map[uuid.UUID]Media, error) {
+	resp := map[uuid.UUID]Media{}
+	if len(ids) == 0 {
+		return resp, nil
+	}
+
+	list, err := q.ListMediaByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := cfg.loadMedia(ctx, q, list)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range loaded {
+		resp[m.ID] = m
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) handlerUsersSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(handles.Normalize(r.URL.Query().Get("q")))
+	if query == "" || len(query) > maxUserSearchQuery {
+		respondWithError(w, http.StatusBadRequest, "Query must be 1 to 50 characters", nil)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// exact matches first, then the most followed
+	rows, err := cfg.dbQueries.SearchUsers(r.Context(), database.SearchUsersParams{
+		Prefix:   likeEscaper.Replace(query) + "%",
+		Query:    query,
+		ViewerID: viewer,
+		MaxUsers: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search users", err)
+		return
+	}
+
+	var avatarIDs []uuid.UUID
+	for _, row := range rows {
+		if row.AvatarMediaID.Valid {
+			avatarIDs = append(avatarIDs, row.AvatarMediaID.UUID)
+		}
+	}
+	avatars, err := cfg.avatars(r.Context(), cfg.dbQueries, avatarIDs...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load avatars", err)
+		return
+	}
+
+	resp := make([]UserSummary, 0, len(rows))
+	for _, row := range rows {
+		user := UserSummary{
+			ID:            row.ID,
+			Handle:        row.Handle.String,
+			DisplayName:   row.DisplayName,
+			IsPrivate:     row.IsPrivate,
+			FollowerCount: row.FollowerCount,
+		}
+		if avatar, ok := avatars[row.AvatarMediaID.UUID]; ok && row.AvatarMediaID.Valid {
+			user.Avatar = &avatar
+		}
+		resp = append(resp, user)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/Weso1ek/chirpy/internal/auth"
 	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/handles"
 	"net/http"
 	"time"
 
@@ -17,39 +19,23 @@ type User struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	IsPrivate   bool      `json:"is_private"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	Website     string    `json:"website"`
+	// AvatarMediaID is resolved to an image by GET /api/users/{handle}
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
 }
 
-func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
-
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-		return
-	}
-
-	user, err := cfg.dbQueries.GetUserByLogin(r.Context(), sql.NullString{String: params.Email, Valid: true})
-
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "User not found", nil)
-	}
-
-	errCompare := auth.CheckPasswordHash(user.HashedPassword.String, params.Password)
-	if errCompare != nil {
-		respondWithError(w, http.StatusUnauthorized, "User not found", nil)
-	}
+type loginResponse struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
 
+// respondWithSession issues an access and refresh token pair for user
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, r *http.Request, user database.User) {
 	accessToken, err := auth.MakeJWT(
 		user.ID,
 		cfg.secret,
@@ -76,25 +62,80 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt.Time,
-			UpdatedAt:   user.UpdatedAt.Time,
-			Email:       user.Email.String,
-			IsChirpyRed: user.IsChirpyRed.Bool,
-		},
+	userResp, err := cfg.userFromDB(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load subscription", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, loginResponse{
+		User:         userResp,
 		Token:        accessToken,
 		RefreshToken: refreshToken,
 	})
 }
 
-func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByLogin(r.Context(), sql.NullString{String: params.Email, Valid: true})
+
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User not found", nil)
+		return
+	}
+
+	errCompare := auth.CheckPasswordHash(user.HashedPassword.String, params.Password)
+	if errCompare != nil {
+		respondWithError(w, http.StatusUnauthorized, "User not found", nil)
+		return
+	}
+
+	cfg.respondWithSession(w, r, user)
+}
+
+// credentialUpdate changes the email and password independently, whichever
+// is left empty keeps its current value
+func credentialUpdate(userID uuid.UUID, email, password string) (database.UpdateUserParams, error) {
+	params := database.UpdateUserParams{
+		ID: userID,
+	}
+	if email != "" {
+		params.Email = sql.NullString{String: email, Valid: true}
+	}
+	if password != "" {
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return database.UpdateUserParams{}, err
+		}
+		params.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+	return params, nil
+}
+
+func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email       string  `json:"email"`
+		Password    string  `json:"password"`
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		Website     *string `json:"website"`
+		// null removes the avatar, leaving it out keeps the current one
+		AvatarMediaID json.RawMessage `json:"avatar_media_id"`
+	}
+
 	type response struct {
 		User
 	}
@@ -118,23 +159,117 @@ func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hashedPassword, errPass := auth.HashPassword(params.Password)
-	if errPass != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to hash password", errPass)
+	user, err := cfg.dbQueries.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
 	}
 
-	user, err := cfg.dbQueries.UpdateUser(r.Context(), database.UpdateUserParams{
-		Email:          sql.NullString{String: params.Email, Valid: true},
-		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
-		ID:             userID,
-	})
+	profile := database.UpdateUserProfileParams{
+		ID:            user.ID,
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarMediaID: user.AvatarMediaID,
+		Website:       user.Website,
+	}
+	if params.Handle != nil {
+		handle := handles.Normalize(*params.Handle)
+		if !handles.Valid(handle) {
+			respondWithError(w, http.StatusBadRequest, "Handle must be 3 to 30 letters, digits or underscores", nil)
+			return
+		}
+		if handles.Reserved(handle) {
+			respondWithError(w, http.StatusBadRequest, "Handle is reserved", nil)
+			return
+		}
+		profile.Handle = sql.NullString{String: handle, Valid: true}
+	}
+	if params.DisplayName != nil {
+		if len(*params.DisplayName) > maxDisplayNameLength {
+			respondWithError(w, http.StatusBadRequest, "Display name is too long", nil)
+			return
+		}
+		profile.DisplayName = *params.DisplayName
+	}
+	if params.Bio != nil {
+		if len(*params.Bio) > maxBioLength {
+			respondWithError(w, http.StatusBadRequest, "Bio is too long", nil)
+			return
+		}
+		profile.Bio = *params.Bio
+	}
+	if params.Website != nil {
+		if *params.Website != "" && (len(*params.Website) > maxWebsiteLength || !validWebsite(*params.Website)) {
+			respondWithError(w, http.StatusBadRequest, "Website must be an http or https URL", nil)
+			return
+		}
+		profile.Website = *params.Website
+	}
+	if len(params.AvatarMediaID) > 0 {
+		var avatarID *uuid.UUID
+		err = json.Unmarshal(params.AvatarMediaID, &avatarID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid avatar media id", err)
+			return
+		}
+		profile.AvatarMediaID = uuid.NullUUID{}
+		if avatarID != nil {
+			m, err := cfg.dbQueries.GetMedia(r.Context(), *avatarID)
+			if err != nil || m.UserID != userID || m.Status != mediaReady {
+				respondWithError(w, http.StatusBadRequest, "Avatar must be your own processed media", err)
+				return
+			}
+			profile.AvatarMediaID = uuid.NullUUID{UUID: *avatarID, Valid: true}
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	if params.Email != "" || params.Password != "" {
+		credentials, err := credentialUpdate(userID, params.Email, params.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to hash password", err)
+			return
+		}
+
+		_, err = q.UpdateUser(r.Context(), credentials)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+			return
+		}
+	}
+
+	user, err = saveProfile(r.Context(), q, user, profile)
+	if errors.Is(err, errHandleTaken) {
+		respondWithError(w, http.StatusConflict, "Handle is already taken", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
+	userResp, err := cfg.userFromDB(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load subscription", err)
+		return
+	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          userID,
-			Email:       user.Email.String,
-			IsChirpyRed: user.IsChirpyRed.Bool,
-		},
+		User: userResp,
 	})
 }
 
@@ -170,13 +305,13 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// a new account can't have a subscription yet
 	respondWithJSON(w, http.StatusCreated, response{
 		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt.Time,
-			UpdatedAt:   user.UpdatedAt.Time,
-			Email:       user.Email.String,
-			IsChirpyRed: user.IsChirpyRed.Bool,
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt.Time,
+			UpdatedAt: user.UpdatedAt.Time,
+			Email:     user.Email.String,
 		},
 	})
 }
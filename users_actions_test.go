@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCredentialUpdate(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		password     string
+		wantEmail    bool
+		wantPassword bool
+	}{
+		{name: "email only", email: "new@example.com", wantEmail: true},
+		{name: "password only", password: "hunter2", wantPassword: true},
+		{name: "both", email: "new@example.com", password: "hunter2", wantEmail: true, wantPassword: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			got, err := credentialUpdate(userID, tt.email, tt.password)
+			if err != nil {
+				t.Fatalf("credentialUpdate() error = %v", err)
+			}
+			if got.ID != userID {
+				t.Errorf("ID = %v, want %v", got.ID, userID)
+			}
+			if got.Email.Valid != tt.wantEmail || got.Email.String != tt.email {
+				t.Errorf("Email = %+v, want set %v to %q", got.Email, tt.wantEmail, tt.email)
+			}
+			if got.HashedPassword.Valid != tt.wantPassword {
+				t.Errorf("HashedPassword set = %v, want %v", got.HashedPassword.Valid, tt.wantPassword)
+			}
+			if tt.wantPassword {
+				err = auth.CheckPasswordHash(got.HashedPassword.String, tt.password)
+				if err != nil {
+					t.Errorf("HashedPassword doesn't match %q: %v", tt.password, err)
+				}
+			}
+		})
+	}
+}
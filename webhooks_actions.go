@@ -1,52 +1,424 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/notifications"
+	"github.com/Weso1ek/chirpy/internal/webhooks"
 	"github.com/google/uuid"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-func (cfg *apiConfig) handlerUserUpgrade(w http.ResponseWriter, r *http.Request) {
-	type parametersData struct {
+const polkaSignatureTolerance = 5 * time.Minute
+
+var errWebhookSigningDisabled = errors.New("POLKA_WEBHOOK_SECRETS isn't set")
+
+// verifyPolkaRequest authenticates a Polka delivery by API key and by HMAC
+// signature over the raw body, deliveries are refused when no signing
+// secret is configured
+func (cfg *apiConfig) verifyPolkaRequest(r *http.Request, body []byte) error {
+	token, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return err
+	}
+
+	validKey := false
+	for _, key := range cfg.polkaKeys {
+		if key != "" && auth.CompareTokens(token, key) {
+			validKey = true
+		}
+	}
+	if !validKey {
+		return fmt.Errorf("unknown api key")
+	}
+
+	if len(cfg.polkaSecrets) == 0 {
+		return errWebhookSigningDisabled
+	}
+
+	timestamp := r.Header.Get("X-Polka-Timestamp")
+	signature := r.Header.Get("X-Polka-Signature")
+	err = auth.VerifyWebhookSignature(
+		body,
+		timestamp,
+		signature,
+		cfg.polkaSecrets,
+		polkaSignatureTolerance,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.polkaReplays.Check(timestamp, signature, time.Now())
+}
+
+// recordPolkaDelivery catches replays the local cache can't see because
+// they were sent to another replica, and returns the delivery's replay key
+func (cfg *apiConfig) recordPolkaDelivery(r *http.Request) (string, error) {
+	key, err := auth.ReplayKey(r.Header.Get("X-Polka-Timestamp"), r.Header.Get("X-Polka-Signature"))
+	if err != nil {
+		return "", err
+	}
+	recorded, err := cfg.dbQueries.RecordWebhookSignature(r.Context(), database.RecordWebhookSignatureParams{
+		Provider:  "polka",
+		ReplayKey: key,
+		ExpiresAt: time.Now().UTC().Add(cfg.polkaReplays.Retention()),
+	})
+	if err != nil {
+		return "", err
+	}
+	if recorded == 0 {
+		return "", auth.ErrReplayedRequest
+	}
+	return key, nil
+}
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
 		UserId string `json:"user_id"`
+		Plan   string `json:"plan"`
+	} `json:"data"`
+}
+
+const (
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+var errEventNotSupported = errors.New("event not supported")
+
+var supportedPolkaEvents = map[string]bool{
+	"user.upgraded":       true,
+	"user.renewed":        true,
+	"user.payment_failed": true,
+	"user.downgraded":     true,
+	"user.refunded":       true,
+}
+
+// processPolkaEvent applies a stored Polka delivery
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, q *database.Queries, eventID uuid.UUID, payload []byte) error {
+	event := polkaEvent{}
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return fmt.Errorf("couldn't decode payload: %w", err)
+	}
+
+	if !supportedPolkaEvents[event.Event] {
+		return errEventNotSupported
+	}
+
+	userId, err := uuid.Parse(event.Data.UserId)
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+
+	plan := event.Data.Plan
+	if plan == "" {
+		plan = planRed
+	}
+
+	switch event.Event {
+	case "user.upgraded":
+		// an upgrade while already Red is a duplicate, not a new period
+		_, err = q.GetActiveSubscription(ctx, userId)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		err = cfg.startSubscriptionPeriod(ctx, q, userId, plan, eventID)
+	case "user.renewed":
+		err = cfg.startSubscriptionPeriod(ctx, q, userId, plan, eventID)
+	case "user.payment_failed":
+		err = cfg.updateSubscriptions(ctx, q, userId, subscriptionStatusPastDue, false)
+	case "user.downgraded":
+		err = cfg.updateSubscriptions(ctx, q, userId, subscriptionStatusCanceled, true)
+	case "user.refunded":
+		err = cfg.updateSubscriptions(ctx, q, userId, subscriptionStatusRefunded, true)
+	default:
+		return errEventNotSupported
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	err = notify(ctx, q, newNotification{
+		UserID:  userId,
+		Type:    notifications.TypeSubscription,
+		Subject: userId,
+		Data: struct {
+			Event string `json:"event"`
+			Plan  string `json:"plan"`
+		}{
+			Event: event.Event,
+			Plan:  plan,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to notify user: %w", err)
+	}
+
+	return webhooks.Publish(ctx, q, webhooks.EventSubscriptionChanged, struct {
+		UserID uuid.UUID `json:"user_id"`
+		Event  string    `json:"event"`
+		Plan   string    `json:"plan"`
+	}{
+		UserID: userId,
+		Event:  event.Event,
+		Plan:   plan,
+	}, webhooks.Recipients(userId))
+}
+
+// updateSubscriptions sets the status of every period of the user's that is
+// running or yet to start, an early renewal leaves one of each. When end is
+// true they stop now. With none open it updates the most recent period, a
+// refund can come after a downgrade.
+func (cfg *apiConfig) updateSubscriptions(ctx context.Context, q *database.Queries, userID uuid.UUID, status string, end bool) error {
+	var updated int64
+	var err error
+	if end {
+		updated, err = q.EndOpenSubscriptions(ctx, database.EndOpenSubscriptionsParams{
+			UserID: userID,
+			Status: status,
+		})
+	} else {
+		updated, err = q.UpdateOpenSubscriptions(ctx, database.UpdateOpenSubscriptionsParams{
+			UserID: userID,
+			Status: status,
+		})
+	}
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	subscription, err := q.GetLatestSubscription(ctx, userID)
+	if err != nil {
+		return err
+	}
+	_, err = q.UpdateSubscriptionStatus(ctx, database.UpdateSubscriptionStatusParams{
+		ID:     subscription.ID,
+		Status: status,
+	})
+	return err
+}
+
+// runWebhookEvent processes event and records the outcome
+func (cfg *apiConfig) runWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	errProcess := cfg.processPolkaEvent(ctx, cfg.dbQueries.WithTx(tx), event.ID, event.Payload)
+	if errProcess == nil {
+		errProcess = tx.Commit()
+	}
+
+	params := database.FinishWebhookEventParams{
+		ID:     event.ID,
+		Status: webhookStatusProcessed,
+	}
+	if errors.Is(errProcess, errEventNotSupported) {
+		params.Status = webhookStatusIgnored
+	} else if errProcess != nil {
+		params.Status = webhookStatusFailed
+		params.Error = sql.NullString{String: errProcess.Error(), Valid: true}
+	}
+
+	err = cfg.dbQueries.FinishWebhookEvent(ctx, params)
+	if err != nil {
+		return err
+	}
+	return errProcess
+}
+
+func (cfg *apiConfig) handlerUserUpgrade(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read body", err)
+		return
+	}
+
+	err = cfg.verifyPolkaRequest(r, body)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't verify webhook", err)
+		return
+	}
+
+	replayKey, err := cfg.recordPolkaDelivery(r)
+	if errors.Is(err, auth.ErrReplayedRequest) {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't verify webhook", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook", err)
+		return
+	}
+
+	params := polkaEvent{}
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// without an id only the signed delivery itself is unique, identical
+	// bodies are legitimate for renewals and re-upgrades
+	eventID := params.ID
+	if eventID == "" {
+		eventID = r.Header.Get("X-Polka-Event-Id")
+	}
+	if eventID == "" {
+		eventID = "sig:" + replayKey
+	}
+
+	event, err := cfg.dbQueries.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:  "polka",
+		EventID:   eventID,
+		EventType: params.Event,
+		Payload:   body,
+	})
+	// no row means the event was already handled or another delivery holds it
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save webhook event", err)
+		return
+	}
+
+	err = cfg.runWebhookEvent(r.Context(), event)
+	if errors.Is(err, errEventNotSupported) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to process webhook", err)
+		return
 	}
 
-	type parameters struct {
-		Event string         `json:"event"`
-		Data  parametersData `json:"data"`
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromDB(event database.WebhookEvent) WebhookEvent {
+	resp := WebhookEvent{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt.Time,
+		UpdatedAt: event.UpdatedAt.Time,
+		Provider:  event.Provider,
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		Payload:   event.Payload,
+		Status:    event.Status,
+		Error:     event.Error.String,
+		Attempts:  event.Attempts,
+	}
+	if event.ProcessedAt.Valid {
+		resp.ProcessedAt = &event.ProcessedAt.Time
 	}
+	return resp
+}
 
-	token, errToken := auth.GetAPIKey(r.Header)
-	if errToken != nil {
-		respondWithError(w, http.StatusUnauthorized, "No token", errToken)
+func (cfg *apiConfig) handlerWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	err := cfg.requireAdmin(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Admin access required", err)
 		return
 	}
 
-	if token != cfg.polkaKey {
-		respondWithError(w, http.StatusUnauthorized, "No token not equal", nil)
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	events, err := cfg.dbQueries.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status: r.URL.Query().Get("status"),
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list webhook events", err)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
+	eventsResp := []WebhookEvent{}
+	for _, event := range events {
+		eventsResp = append(eventsResp, webhookEventFromDB(event))
+	}
+
+	respondWithJSON(w, http.StatusOK, eventsResp)
+}
+
+func (cfg *apiConfig) handlerWebhookEventReplay(w http.ResponseWriter, r *http.Request) {
+	err := cfg.requireAdmin(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusUnauthorized, "Admin access required", err)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		respondWithError(w, http.StatusNoContent, "Event not supported", fmt.Errorf("Event not supported"))
+	eventUUID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event id", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetWebhookEvent(r.Context(), eventUUID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook event", err)
+		return
+	}
+
+	event, err := cfg.dbQueries.ClaimWebhookEvent(r.Context(), eventUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Only failed events can be replayed", nil)
+		return
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't claim webhook event", err)
+		return
+	}
+
+	errRun := cfg.runWebhookEvent(r.Context(), event)
 
-	userId, _ := uuid.Parse(params.Data.UserId)
+	event, err = cfg.dbQueries.GetWebhookEvent(r.Context(), eventUUID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reload webhook event", err)
+		return
+	}
 
-	_, errUpdate := cfg.dbQueries.UpdateUserRed(r.Context(), userId)
-	if errUpdate != nil {
-		respondWithError(w, http.StatusNotFound, "Failed to update user", errUpdate)
+	if errRun != nil && !errors.Is(errRun, errEventNotSupported) {
+		respondWithJSON(w, http.StatusUnprocessableEntity, webhookEventFromDB(event))
+		return
 	}
 
-	respondWithJSON(w, http.StatusNoContent, nil)
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
 }
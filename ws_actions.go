@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/Weso1ek/chirpy/internal/auth"
+	"github.com/Weso1ek/chirpy/internal/database"
+	"github.com/Weso1ek/chirpy/internal/realtime"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsQueueSize      = 64
+	wsMaxMessageSize = 4096
+	wsAuthTimeout    = 10 * time.Second
+	wsPingInterval   = 30 * time.Second
+	wsPongTimeout    = 60 * time.Second
+	wsWriteTimeout   = 10 * time.Second
+	wsReauthGrace    = 30 * time.Second
+
+	wsCloseAuthFailed = 4001
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type wsClientMessage struct {
+	Type   string   `json:"type"`
+	Token  string   `json:"token,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type      string     `json:"type"`
+	Topics    []string   `json:"topics,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// publishRealtime sends msg to subscribers on every instance once q's
+// transaction commits
+func publishRealtime(ctx context.Context, q *database.Queries, topic, msgType string, data any) error {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return q.PublishRealtimeMessage(ctx, database.PublishRealtimeMessageParams{
+		Topic: topic,
+		Type:  msgType,
+		Data:  dat,
+	})
+}
+
+// loadRealtimeMessage fetches a message published on any instance
+func (cfg *apiConfig) loadRealtimeMessage(ctx context.Context, id uuid.UUID) (realtime.Message, error) {
+	msg, err := cfg.dbQueries.GetRealtimeMessage(ctx, id)
+	if err != nil {
+		return realtime.Message{}, err
+	}
+	return realtime.Message{
+		Topic: msg.Topic,
+		Type:  msg.Type,
+		Data:  msg.Data,
+	}, nil
+}
+
+// authorizeTopic reports whether userID may subscribe to topic
+func (cfg *apiConfig) authorizeTopic(ctx context.Context, userID uuid.UUID, topic string) error {
+	if topic == realtime.NotificationsTopic(userID) {
+		return nil
+	}
+	if chirpID, ok := realtime.ParseChirpTopic(topic); ok {
+		_, err := cfg.dbQueries.GetChirpForViewer(ctx, database.GetChirpForViewerParams{
+			ID:       chirpID,
+			ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		return err
+	}
+	return errors.New("unknown topic")
+}
+
+// wsSession is the state of one authenticated connection
+type wsSession struct {
+	conn    *websocket.Conn
+	client  *realtime.Client
+	control chan wsServerMessage
+	reauth  chan time.Time
+	done    chan struct{}
+}
+
+func (s *wsSession) reply(msg wsServerMessage) {
+	select {
+	case s.control <- msg:
+	default:
+		// a client that doesn't read its replies is as slow as any other
+		s.conn.Close()
+	}
+}
+
+func (cfg *apiConfig) handlerWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already written an error response
+		log.Printf("Websocket upgrade failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	// browsers can't set headers on websockets, so the token may also
+	// arrive as the first message
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+		msg := wsClientMessage{}
+		err = conn.ReadJSON(&msg)
+		if err != nil || msg.Type != "auth" {
+			closeWebsocket(conn, wsCloseAuthFailed, "authentication required")
+			return
+		}
+		token = msg.Token
+	}
+
+	userID, expiresAt, err := auth.ValidateJWTExpiry(token, cfg.secret)
+	if err == nil {
+		err = cfg.liveUser(r.Context(), userID)
+	}
+	if err != nil {
+		closeWebsocket(conn, wsCloseAuthFailed, "invalid token")
+		return
+	}
+
+	session := &wsSession{
+		conn:    conn,
+		client:  cfg.realtime.NewClient(wsQueueSize),
+		control: make(chan wsServerMessage, 16),
+		reauth:  make(chan time.Time, 1),
+		done:    make(chan struct{}),
+	}
+	defer cfg.realtime.Remove(session.client)
+
+	own := realtime.NotificationsTopic(userID)
+	cfg.realtime.Subscribe(session.client, own)
+	session.reply(wsServerMessage{Type: "subscribed", Topics: []string{own}})
+
+	go cfg.readWebsocket(r.Context(), session, userID)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+	reauthPending := false
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-session.client.Kicked:
+			closeWebsocket(conn, websocket.CloseTryAgainLater, "too slow, reconnect")
+			return
+		case msg := <-session.client.Send:
+			// a block, an unfollow or a visibility change since subscribing
+			// ends a chirp topic, it's checked again before every message
+			if _, ok := realtime.ParseChirpTopic(msg.Topic); ok {
+				err := cfg.authorizeTopic(r.Context(), userID, msg.Topic)
+				if errors.Is(err, sql.ErrNoRows) {
+					cfg.realtime.Unsubscribe(session.client, msg.Topic)
+					if writeWebsocket(conn, wsServerMessage{Type: "unsubscribed", Topics: []string{msg.Topic}}) != nil {
+						return
+					}
+					continue
+				}
+				if err != nil {
+					log.Printf("Couldn't check topic %s: %s", msg.Topic, err)
+					continue
+				}
+			}
+			if writeWebsocket(conn, msg) != nil {
+				return
+			}
+		case msg := <-session.control:
+			if writeWebsocket(conn, msg) != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if conn.WriteMessage(websocket.PingMessage, nil) != nil {
+				return
+			}
+		case newExpiry := <-session.reauth:
+			reauthPending = false
+			expiresAt = newExpiry
+			expiry.Reset(time.Until(expiresAt))
+		case <-expiry.C:
+			if reauthPending {
+				closeWebsocket(conn, wsCloseAuthFailed, "token expired")
+				return
+			}
+			reauthPending = true
+			if writeWebsocket(conn, wsServerMessage{Type: "reauth_required", ExpiresAt: &expiresAt}) != nil {
+				return
+			}
+			expiry.Reset(wsReauthGrace)
+		}
+	}
+}
+
+// readWebsocket handles client messages until the connection fails
+func (cfg *apiConfig) readWebsocket(ctx context.Context, s *wsSession, userID uuid.UUID) {
+	defer close(s.done)
+
+	s.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		msg := wsClientMessage{}
+		err := s.conn.ReadJSON(&msg)
+		if err != nil {
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		switch msg.Type {
+		case "ping":
+			s.reply(wsServerMessage{Type: "pong"})
+		case "auth":
+			newUserID, expiresAt, err := auth.ValidateJWTExpiry(msg.Token, cfg.secret)
+			if err == nil {
+				err = cfg.liveUser(ctx, newUserID)
+			}
+			if err != nil || newUserID != userID {
+				s.reply(wsServerMessage{Type: "error", Error: "invalid token"})
+				continue
+			}
+			select {
+			case s.reauth <- expiresAt:
+			default:
+			}
+		case "subscribe":
+			var subscribed []string
+			for _, topic := range msg.Topics {
+				err := cfg.authorizeTopic(ctx, userID, topic)
+				if err != nil {
+					s.reply(wsServerMessage{Type: "error", Error: "can't subscribe to " + topic})
+					continue
+				}
+				cfg.realtime.Subscribe(s.client, topic)
+				subscribed = append(subscribed, topic)
+			}
+			s.reply(wsServerMessage{Type: "subscribed", Topics: subscribed})
+		case "unsubscribe":
+			for _, topic := range msg.Topics {
+				cfg.realtime.Unsubscribe(s.client, topic)
+			}
+			s.reply(wsServerMessage{Type: "unsubscribed", Topics: msg.Topics})
+		default:
+			s.reply(wsServerMessage{Type: "error", Error: "unknown message type"})
+		}
+	}
+}
+
+func writeWebsocket(conn *websocket.Conn, v any) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(v)
+}
+
+func closeWebsocket(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(wsWriteTimeout),
+	)
+}